@@ -0,0 +1,36 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// ANSI escapes for coloring output.
+const (
+	colorReset  = "\x1b[0m"
+	colorBold   = "\x1b[1m"
+	colorDim    = "\x1b[2m"
+	colorRed    = "\x1b[31m"
+	colorGreen  = "\x1b[32m"
+	colorYellow = "\x1b[33m"
+)
+
+// useColor for output to w in the given mode, one of auto, always or never.
+// Auto colors terminals unless NO_COLOR is set, see https://no-color.org.
+func useColor(mode string, w io.Writer) (bool, error) {
+	f, _ := w.(*os.File)
+	switch mode {
+	case "auto":
+		return os.Getenv("NO_COLOR") == "" && isTerminal(w) && enableColor(f), nil
+	case "always":
+		// Whatever ends up reading it might still support it.
+		if f != nil {
+			enableColor(f)
+		}
+		return true, nil
+	case "never":
+		return false, nil
+	}
+	return false, fmt.Errorf("unknown color mode %q", mode)
+}
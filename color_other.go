@@ -0,0 +1,10 @@
+//go:build !windows
+
+package main
+
+import "os"
+
+// enableColor escapes on f, which terminals other than Windows' support as is.
+func enableColor(f *os.File) bool {
+	return true
+}
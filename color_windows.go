@@ -0,0 +1,24 @@
+//go:build windows
+
+package main
+
+import (
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// enableColor escapes on f by turning on virtual terminal processing,
+// reporting whether the console supports it.
+func enableColor(f *os.File) bool {
+	h := windows.Handle(f.Fd())
+	var mode uint32
+	err := windows.GetConsoleMode(h, &mode)
+	if err != nil {
+		return false
+	}
+	if mode&windows.ENABLE_VIRTUAL_TERMINAL_PROCESSING != 0 {
+		return true
+	}
+	return windows.SetConsoleMode(h, mode|windows.ENABLE_VIRTUAL_TERMINAL_PROCESSING) == nil
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/vikblom/go-latest/golatest"
+)
+
+// subcommands of go-latest, besides upgrading.
+var subcommands = []string{"apply", "cache", "completion", "doctor", "list", "self-update", "watch"}
+
+// flagValues of flags taking one of a few values.
+var flagValues = map[string][]string{
+	"color":           {"auto", "always", "never"},
+	"log-format":      {"text", "json"},
+	"log-level":       {"debug", "info", "warn", "error"},
+	"sort":            {"path", "status", "duration"},
+	"parallel-output": {"buffer", "stream"},
+}
+
+// completion script for shell, completing the subcommands, the flags of
+// fs, the values of those in flagValues and the names of the programs in
+// GOBIN as arguments, as go-latest __programs lists them at the time.
+func completion(w io.Writer, shell string, fs *flag.FlagSet) error {
+	type flagInfo struct {
+		name, usage string
+		// arg names the value of a flag taking one, e.g. "file".
+		arg string
+	}
+	var flags []flagInfo
+	fs.VisitAll(func(f *flag.Flag) {
+		fi := flagInfo{name: f.Name}
+		fi.arg, fi.usage = flag.UnquoteUsage(f)
+		if b, ok := f.Value.(interface{ IsBoolFlag() bool }); ok && b.IsBoolFlag() {
+			fi.arg = ""
+		}
+		flags = append(flags, fi)
+	})
+	sort.Slice(flags, func(i, j int) bool { return flags[i].name < flags[j].name })
+
+	var b strings.Builder
+	switch shell {
+	case "bash":
+		var names, files, values []string
+		for _, f := range flags {
+			names = append(names, "-"+f.name)
+			switch {
+			case f.arg == "file":
+				files = append(files, "-"+f.name)
+			case f.arg != "" && flagValues[f.name] == nil:
+				values = append(values, "-"+f.name)
+			}
+		}
+		b.WriteString("_go_latest() {\n")
+		b.WriteString("\tlocal cur=\"${COMP_WORDS[COMP_CWORD]}\" prev=\"${COMP_WORDS[COMP_CWORD-1]}\"\n")
+		b.WriteString("\tcase \"$prev\" in\n")
+		fmt.Fprintf(&b, "\t%s)\n\t\tCOMPREPLY=($(compgen -f -- \"$cur\"))\n\t\treturn ;;\n", strings.Join(files, "|"))
+		for _, f := range flags {
+			if vs := flagValues[f.name]; vs != nil {
+				fmt.Fprintf(&b, "\t-%s)\n\t\tCOMPREPLY=($(compgen -W %q -- \"$cur\"))\n\t\treturn ;;\n", f.name, strings.Join(vs, " "))
+			}
+		}
+		fmt.Fprintf(&b, "\t%s)\n\t\treturn ;;\n", strings.Join(values, "|"))
+		b.WriteString("\tcompletion)\n\t\tCOMPREPLY=($(compgen -W \"bash zsh fish\" -- \"$cur\"))\n\t\treturn ;;\n")
+		b.WriteString("\tesac\n")
+		fmt.Fprintf(&b, "\tlocal words=%q\n", strings.Join(names, " "))
+		fmt.Fprintf(&b, "\t[[ $COMP_CWORD -eq 1 ]] && words=\"%s $words\"\n", strings.Join(subcommands, " "))
+		b.WriteString("\tcase \"${COMP_WORDS[1]}\" in\n")
+		fmt.Fprintf(&b, "\t%s) ;;\n", strings.Join(subcommands, "|"))
+		b.WriteString("\t*) [[ $cur == -* ]] || words=\"$words $(go-latest __programs 2>/dev/null)\" ;;\n")
+		b.WriteString("\tesac\n")
+		b.WriteString("\tCOMPREPLY=($(compgen -W \"$words\" -- \"$cur\"))\n")
+		b.WriteString("}\n")
+		b.WriteString("complete -F _go_latest go-latest\n")
+
+	case "zsh":
+		b.WriteString("#compdef go-latest\n\n")
+		b.WriteString("_go_latest_programs() {\n")
+		b.WriteString("\tcompadd -- ${(f)\"$(go-latest __programs 2>/dev/null)\"}\n")
+		b.WriteString("}\n\n")
+		b.WriteString("_arguments \\\n")
+		for _, f := range flags {
+			// Brackets and colons are special in a spec.
+			usage := strings.NewReplacer("[", "(", "]", ")", ":", " -", "'", "").Replace(f.usage)
+			spec := fmt.Sprintf("-%s[%s]", f.name, usage)
+			switch {
+			case f.arg == "file":
+				spec += ":file:_files"
+			case flagValues[f.name] != nil:
+				spec += fmt.Sprintf(":%s:(%s)", f.arg, strings.Join(flagValues[f.name], " "))
+			case f.arg != "":
+				spec += ":" + f.arg + ":"
+			}
+			fmt.Fprintf(&b, "\t'%s' \\\n", spec)
+		}
+		fmt.Fprintf(&b, "\t'1::command or program:{compadd %s; _go_latest_programs}' \\\n", strings.Join(subcommands, " "))
+		b.WriteString("\t'*::program:_go_latest_programs'\n")
+
+	case "fish":
+		fmt.Fprintf(&b, "complete -c go-latest -f -n __fish_use_subcommand -a '%s'\n", strings.Join(subcommands, " "))
+		b.WriteString("complete -c go-latest -f -n '__fish_seen_subcommand_from completion' -a 'bash zsh fish'\n")
+		fmt.Fprintf(&b, "complete -c go-latest -f -n 'not __fish_seen_subcommand_from %s' -a '(go-latest __programs 2>/dev/null)'\n", strings.Join(subcommands, " "))
+		for _, f := range flags {
+			fmt.Fprintf(&b, "complete -c go-latest -o %s -d '%s'", f.name, strings.ReplaceAll(f.usage, "'", `\'`))
+			switch {
+			case f.arg == "file":
+				b.WriteString(" -r -F")
+			case flagValues[f.name] != nil:
+				fmt.Fprintf(&b, " -x -a '%s'", strings.Join(flagValues[f.name], " "))
+			case f.arg != "":
+				b.WriteString(" -x")
+			}
+			b.WriteString("\n")
+		}
+
+	default:
+		return fmt.Errorf("completion: unknown shell %q, try bash, zsh or fish", shell)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// programNames of the Go programs in dir, one a line, for completion.
+func programNames(w io.Writer, dir string) error {
+	results, err := golatest.List(dir)
+	if err != nil {
+		return err
+	}
+	var names []string
+	for _, r := range results {
+		names = append(names, filepath.Base(r.File))
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		_, err = fmt.Fprintln(w, name)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// completionFlags of each kind completion tells apart.
+func completionFlags() *flag.FlagSet {
+	fs := flag.NewFlagSet("go-latest", flag.ContinueOnError)
+	fs.Bool("dry-run", false, "Only print what would be done")
+	fs.String("sync", "", "Install the tools of the manifest `file`")
+	fs.String("sort", "path", "Order by `key`: path, status or duration")
+	fs.Int("workers", 4, "Run up to `n` lookups at once")
+	return fs
+}
+
+func TestCompletion(t *testing.T) {
+	for _, tt := range []struct {
+		shell string
+		want  []string
+	}{
+		{"bash", []string{
+			"complete -F _go_latest go-latest\n",
+			"\t-sync)\n\t\tCOMPREPLY=($(compgen -f -- \"$cur\"))\n",
+			"\t-sort)\n\t\tCOMPREPLY=($(compgen -W \"path status duration\" -- \"$cur\"))\n",
+			"\t-workers)\n\t\treturn ;;\n",
+			"local words=\"-dry-run -sort -sync -workers\"\n",
+			"words=\"apply cache completion doctor list self-update watch $words\"",
+			"\t*) [[ $cur == -* ]] || words=\"$words $(go-latest __programs 2>/dev/null)\" ;;\n",
+		}},
+		{"zsh", []string{
+			"#compdef go-latest\n",
+			"\t'-dry-run[Only print what would be done]' \\\n",
+			"\t'-sync[Install the tools of the manifest file]:file:_files' \\\n",
+			"\t'-sort[Order by key - path, status or duration]:key:(path status duration)' \\\n",
+			"\t'-workers[Run up to n lookups at once]:n:' \\\n",
+			"\tcompadd -- ${(f)\"$(go-latest __programs 2>/dev/null)\"}\n",
+			"\t'1::command or program:{compadd apply cache completion doctor list self-update watch; _go_latest_programs}' \\\n",
+			"\t'*::program:_go_latest_programs'\n",
+		}},
+		{"fish", []string{
+			"complete -c go-latest -f -n __fish_use_subcommand -a 'apply cache completion doctor list self-update watch'\n",
+			"complete -c go-latest -f -n 'not __fish_seen_subcommand_from apply cache completion doctor list self-update watch' -a '(go-latest __programs 2>/dev/null)'\n",
+			"complete -c go-latest -o dry-run -d 'Only print what would be done'\n",
+			"complete -c go-latest -o sync -d 'Install the tools of the manifest file' -r -F\n",
+			"complete -c go-latest -o sort -d 'Order by key: path, status or duration' -x -a 'path status duration'\n",
+			"complete -c go-latest -o workers -d 'Run up to n lookups at once' -x\n",
+		}},
+	} {
+		var b strings.Builder
+		if err := completion(&b, tt.shell, completionFlags()); err != nil {
+			t.Errorf("%s: %v", tt.shell, err)
+			continue
+		}
+		for _, want := range tt.want {
+			if !strings.Contains(b.String(), want) {
+				t.Errorf("%s completion lacks %q:\n%s", tt.shell, want, b.String())
+			}
+		}
+	}
+}
+
+func TestCompletionUnknownShell(t *testing.T) {
+	var b strings.Builder
+	err := completion(&b, "powershell", completionFlags())
+	if err == nil || !strings.Contains(err.Error(), `unknown shell "powershell"`) {
+		t.Errorf("got %v, want an unknown shell", err)
+	}
+	if b.Len() > 0 {
+		t.Errorf("wrote %q for an unknown shell", b.String())
+	}
+}
+
+// TestCompletionFlags of go-latest itself, every flag with values to
+// complete being one, in a script the shell parses.
+func TestCompletionFlags(t *testing.T) {
+	var b strings.Builder
+	flags := flag.NewFlagSet("go-latest", flag.ContinueOnError)
+	err := runMain(context.Background(), []string{"completion", "bash"}, strings.NewReader(""), &b, &b, flags, fakeGo{})
+	if err != nil {
+		t.Fatalf("completion bash: %v: %s", err, b.String())
+	}
+	out := b.String()
+	for name := range flagValues {
+		if !strings.Contains(out, "\t-"+name+")\n") {
+			t.Errorf("-%s of flagValues is not a flag completed", name)
+		}
+	}
+	if _, err := exec.LookPath("bash"); err != nil {
+		t.Skip("no bash to parse the script with")
+	}
+	cmd := exec.Command("bash", "-n")
+	cmd.Stdin = strings.NewReader(out)
+	if msg, err := cmd.CombinedOutput(); err != nil {
+		t.Errorf("bash -n (%v): %s", err, msg)
+	}
+}
+
+func TestProgramNames(t *testing.T) {
+	// A Go program to list, under several names.
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	prog, err := os.ReadFile(exe)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	for _, name := range []string{"tool", "gopls"} {
+		err = os.WriteFile(filepath.Join(dir, name), prog, 0o755)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = os.WriteFile(filepath.Join(dir, "script"), []byte("#!/bin/sh\n"), 0o755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	var b strings.Builder
+	flags := flag.NewFlagSet("go-latest", flag.ContinueOnError)
+	err = runMain(context.Background(), []string{"__programs", "-gobin", dir}, strings.NewReader(""), &b, &b, flags, fakeGo{})
+	if err != nil {
+		t.Fatalf("__programs: %v: %s", err, b.String())
+	}
+	if want := "gopls\ntool\n"; b.String() != want {
+		t.Errorf("listed %q, want %q", b.String(), want)
+	}
+}
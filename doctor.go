@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"debug/buildinfo"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+
+	"github.com/vikblom/go-latest/golatest"
+)
+
+// binDirs go install'd programs may be in, dir first then the bin of
+// each GOPATH entry, as go env run with runner reports it.
+func binDirs(ctx context.Context, runner golatest.Runner, dir string) []string {
+	dirs := []string{dir}
+	gopath := os.Getenv("GOPATH")
+	if env, err := golatest.GoEnv(ctx, runner, nil, "GOPATH"); err == nil {
+		gopath = env["GOPATH"]
+	}
+	for _, p := range filepath.SplitList(gopath) {
+		if p != "" {
+			dirs = append(dirs, filepath.Join(p, "bin"))
+		}
+	}
+	return dirs
+}
+
+// doctor checks that the programs in dirs are the ones run from path,
+// printing those shadowed by another of the same name further up path, or
+// shadowing one, and the dirs not on path at all, e.g.
+//
+//	gopls: /usr/local/bin/gopls (v0.14.0) shadows /home/me/go/bin/gopls (v0.15.1)
+//	/home/me/go/bin is not on PATH
+//
+// Shadowing another at the same version is noted, but only different
+// versions fail it.
+func doctor(w io.Writer, dirs []string, path string) error {
+	var pathDirs []string
+	for _, d := range filepath.SplitList(path) {
+		if abs, err := filepath.Abs(d); err == nil && !slices.Contains(pathDirs, abs) {
+			pathDirs = append(pathDirs, abs)
+		}
+	}
+	problems := 0
+	names := map[string]bool{}
+	var checked []string
+	for _, d := range dirs {
+		d, err := filepath.Abs(d)
+		if err != nil || slices.Contains(checked, d) {
+			continue
+		}
+		checked = append(checked, d)
+		results, err := golatest.List(d)
+		if err != nil {
+			// E.g. a GOPATH without a bin.
+			continue
+		}
+		if !slices.Contains(pathDirs, d) && len(results) > 0 {
+			fmt.Fprintf(w, "%s is not on PATH\n", d)
+			problems++
+		}
+		for _, r := range results {
+			names[filepath.Base(r.File)] = true
+		}
+	}
+
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+	for _, name := range sorted {
+		found := onPath(pathDirs, name)
+		if len(found) < 2 {
+			continue
+		}
+		first, v := found[0], builtVersion(found[0])
+		for _, f := range found[1:] {
+			fv := builtVersion(f)
+			fmt.Fprintf(w, "%s: %s (%s) shadows %s (%s)\n", name, first, v, f, fv)
+			if fv != v {
+				problems++
+			}
+		}
+	}
+	if problems > 0 {
+		return fmt.Errorf("%d problems found", problems)
+	}
+	return nil
+}
+
+// onPath the executables named name in dirs, in order, each file once,
+// however many links to it there are.
+func onPath(dirs []string, name string) []string {
+	var found []string
+	var seen []os.FileInfo
+	for _, d := range dirs {
+		f := filepath.Join(d, name)
+		fi, err := os.Stat(f)
+		if err != nil || fi.IsDir() || fi.Mode().Perm()&0111 == 0 {
+			continue
+		}
+		if slices.ContainsFunc(seen, func(s os.FileInfo) bool { return os.SameFile(s, fi) }) {
+			continue
+		}
+		seen = append(seen, fi)
+		found = append(found, f)
+	}
+	return found
+}
+
+// builtVersion of the Go program file was built from, as told by its build
+// info.
+func builtVersion(file string) string {
+	info, err := buildinfo.ReadFile(file)
+	if err != nil {
+		return "not a Go program"
+	}
+	return info.Main.Version
+}
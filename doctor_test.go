@@ -0,0 +1,71 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestDoctor(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("programs without .exe")
+	}
+	// A Go program to find on PATH, under several names.
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	prog, err := os.ReadFile(exe)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, second, off := t.TempDir(), t.TempDir(), t.TempDir()
+	for file, content := range map[string][]byte{
+		filepath.Join(first, "tool"):   prog,
+		filepath.Join(second, "tool"):  prog,
+		filepath.Join(first, "other"):  prog,
+		filepath.Join(second, "other"): []byte("#!/bin/sh\n"),
+		filepath.Join(second, "alone"): prog,
+		filepath.Join(off, "solo"):     prog,
+	} {
+		if err := os.WriteFile(file, content, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	v := builtVersion(exe)
+
+	for _, tt := range []struct {
+		name string
+		dirs []string
+		path string
+		want string
+		err  string
+	}{
+		{
+			"shadowed", []string{first, second, off}, strings.Join([]string{first, second}, string(os.PathListSeparator)),
+			off + " is not on PATH\n" +
+				"other: " + filepath.Join(first, "other") + " (" + v + ") shadows " + filepath.Join(second, "other") + " (not a Go program)\n" +
+				"tool: " + filepath.Join(first, "tool") + " (" + v + ") shadows " + filepath.Join(second, "tool") + " (" + v + ")\n",
+			"2 problems found",
+		},
+		// Only the other way around is there something else to run.
+		{
+			"shadowing", []string{second}, strings.Join([]string{second, first}, string(os.PathListSeparator)),
+			"tool: " + filepath.Join(second, "tool") + " (" + v + ") shadows " + filepath.Join(first, "tool") + " (" + v + ")\n",
+			"",
+		},
+		{"alone", []string{second}, second, "", ""},
+		{"none on path", []string{off}, "", off + " is not on PATH\n", "1 problems found"},
+	} {
+		var b strings.Builder
+		err := doctor(&b, tt.dirs, tt.path)
+		if b.String() != tt.want {
+			t.Errorf("%s: wrote\n%s\nwant\n%s", tt.name, b.String(), tt.want)
+		}
+		if tt.err != "" && (err == nil || err.Error() != tt.err) || tt.err == "" && err != nil {
+			t.Errorf("%s: %v, want %q", tt.name, err, tt.err)
+		}
+	}
+}
@@ -1,8 +1,10 @@
 module github.com/vikblom/go-latest
 
-go 1.19
+go 1.21
 
 require (
 	golang.org/x/mod v0.7.0
 	golang.org/x/sync v0.1.0
+	golang.org/x/sys v0.5.0
+	golang.org/x/term v0.5.0
 )
@@ -0,0 +1,248 @@
+package golatest
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math/rand"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxAttempts at a lookup the proxy turns away, before giving up on it.
+const maxAttempts = 5
+
+// DefaultLookupTimeout of each go list looking up versions.
+const DefaultLookupTimeout = 30 * time.Second
+
+// maxTimeouts of a lookup to try again after, before giving up on it.
+const maxTimeouts = 2
+
+// throttledRE matches the proxy responses worth trying again, rate limits
+// and server errors, as go reports them, e.g.
+// "reading https://proxy.golang.org/foo/@v/list: 429 Too Many Requests".
+var throttledRE = regexp.MustCompile(`: (429|5\d\d) [A-Z]`)
+
+// throttled reports whether the proxy turned a lookup away with err, or
+// the stderr of go when it did.
+func throttled(msg string) bool {
+	return throttledRE.MatchString(msg)
+}
+
+// installAttempts at go install failing to fetch, before giving up on it.
+const installAttempts = 3
+
+// transientRE matches go install failing to fetch in ways that may well
+// not happen again, e.g.
+// "read tcp 10.0.0.2:51234->142.250.74.113:443: read: connection reset by peer".
+var transientRE = regexp.MustCompile(`connection reset by peer|i/o timeout|TLS handshake timeout|timeout awaiting response headers|unexpected EOF|: (429|5\d\d) [A-Z]`)
+
+// transient reports whether the output of a failed go install says it is
+// worth trying again. A checksum mismatch never is, whatever else failed.
+func transient(out []byte) bool {
+	if bytes.Contains(out, []byte("checksum mismatch")) || bytes.Contains(out, []byte("SECURITY ERROR")) {
+		return false
+	}
+	return transientRE.Match(out)
+}
+
+// backoff before attempt, doubling from half a second up to 8, less up
+// to half of it at random so that those turned away together spread out.
+func backoff(attempt int) time.Duration {
+	d := min(500*time.Millisecond<<(attempt-2), 8*time.Second)
+	return d - time.Duration(rand.Int63n(int64(d/2)))
+}
+
+// sleep for d, or until ctx is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// throttle limits how many lookups run at once, fewer than all workers
+// while the proxy turns them away: the limit halves on each lookup turned
+// away and grows back by one with each let through.
+type throttle struct {
+	max int
+
+	mu             sync.Mutex
+	limit, running int
+	// wake is closed when a slot may have opened up.
+	wake chan struct{}
+}
+
+func newThrottle(n int) *throttle {
+	n = max(n, 1)
+	return &throttle{max: n, limit: n, wake: make(chan struct{})}
+}
+
+// acquire a slot, returning a func to release it with.
+func (t *throttle) acquire(ctx context.Context) (func(), error) {
+	for {
+		t.mu.Lock()
+		if t.running < t.limit {
+			t.running++
+			t.mu.Unlock()
+			return t.release, nil
+		}
+		wake := t.wake
+		t.mu.Unlock()
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-wake:
+		}
+	}
+}
+
+func (t *throttle) release() {
+	t.mu.Lock()
+	t.running--
+	t.signal()
+	t.mu.Unlock()
+}
+
+// refused lookup by the proxy, halving the limit.
+func (t *throttle) refused() {
+	t.mu.Lock()
+	t.limit = max(t.limit/2, 1)
+	t.mu.Unlock()
+}
+
+// passed lookup, raising the limit back towards max.
+func (t *throttle) passed() {
+	t.mu.Lock()
+	if t.limit < t.max {
+		t.limit++
+		t.signal()
+	}
+	t.mu.Unlock()
+}
+
+// signal those waiting in acquire. Called with mu held.
+func (t *throttle) signal() {
+	close(t.wake)
+	t.wake = make(chan struct{})
+}
+
+// withTimeout of a single lookup, if any.
+func (r *resolver) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if r.timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, r.timeout)
+}
+
+// timedOut lookup of what, noting that it is tried again unless it has
+// been timeouts times already.
+func (r *resolver) timedOut(what string, timeouts int) (again bool) {
+	if timeouts > maxTimeouts {
+		return false
+	}
+	if r.log != nil {
+		r.log.Debug("lookup timed out, trying again", "modules", what, "timeout", r.timeout)
+	}
+	return true
+}
+
+// listRetrying is list, trying again with backoff for the modules the
+// proxy turned away, up to maxAttempts in all, and for those timed out,
+// up to maxTimeouts more times.
+func (r *resolver) listRetrying(ctx context.Context, mods []string, versions bool) map[string]lookup {
+	found := map[string]lookup{}
+	timeouts := 0
+	for attempt := 1; ; attempt++ {
+		if attempt > 1 && sleep(ctx, backoff(attempt)) != nil {
+			return found
+		}
+		release, err := r.throttle.acquire(ctx)
+		if err != nil {
+			return found
+		}
+		lctx, cancel := r.withTimeout(ctx)
+		listed := r.list(lctx, mods, versions)
+		expired := lctx.Err() != nil
+		cancel()
+		release()
+		if ctx.Err() != nil {
+			return found
+		}
+		if expired {
+			timeouts++
+			if r.timedOut(strings.Join(mods, " "), timeouts) {
+				continue
+			}
+			for _, mod := range mods {
+				found[mod] = lookup{err: fmt.Errorf("go list: timed out after %s, %d times", r.timeout, timeouts)}
+			}
+			return found
+		}
+		var again []string
+		for mod, l := range listed {
+			l.took += found[mod].took
+			if l.err != nil && throttled(l.err.Error()) {
+				if attempt < maxAttempts {
+					again = append(again, mod)
+				} else {
+					l.err = fmt.Errorf("%w (gave up after %d attempts)", l.err, attempt)
+				}
+			}
+			found[mod] = l
+		}
+		if len(again) == 0 {
+			r.throttle.passed()
+			return found
+		}
+		r.throttle.refused()
+		mods = again
+	}
+}
+
+// goRetrying is goCmd for a lookup, trying again with backoff while the
+// proxy turns it away, up to maxAttempts in all, and when it times out,
+// up to maxTimeouts more times.
+func (r *resolver) goRetrying(ctx context.Context, args ...string) (stdout, stderr []byte, err error) {
+	timeouts := 0
+	for attempt := 1; ; attempt++ {
+		if attempt > 1 {
+			if err := sleep(ctx, backoff(attempt)); err != nil {
+				return nil, nil, err
+			}
+		}
+		release, err := r.throttle.acquire(ctx)
+		if err != nil {
+			return nil, nil, err
+		}
+		lctx, cancel := r.withTimeout(ctx)
+		stdout, stderr, err = r.goCmd(lctx, args...)
+		expired := lctx.Err() != nil
+		cancel()
+		release()
+		if ctx.Err() != nil {
+			return stdout, stderr, ctx.Err()
+		}
+		if expired {
+			timeouts++
+			if r.timedOut(args[len(args)-1], timeouts) {
+				continue
+			}
+			return stdout, stderr, fmt.Errorf("timed out after %s, %d times", r.timeout, timeouts)
+		}
+		if err == nil || !throttled(string(stderr)) {
+			r.throttle.passed()
+			return stdout, stderr, err
+		}
+		r.throttle.refused()
+		if attempt == maxAttempts {
+			return stdout, stderr, fmt.Errorf("%w, gave up after %d attempts", err, attempt)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package golatest
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestTransient(t *testing.T) {
+	for _, tt := range []struct {
+		out  string
+		want bool
+	}{
+		{"go: example.com/tool@v1.0.0: Get \"https://proxy.golang.org/example.com/tool/@v/v1.0.0.zip\": read tcp 10.0.0.2:51234->142.250.74.113:443: read: connection reset by peer", true},
+		{"dial tcp 142.250.74.113:443: i/o timeout", true},
+		{"net/http: TLS handshake timeout", true},
+		{"net/http: timeout awaiting response headers", true},
+		{"unexpected EOF", true},
+		{"reading https://proxy.golang.org/example.com/tool/@v/v1.0.0.zip: 429 Too Many Requests", true},
+		{"reading https://proxy.golang.org/example.com/tool/@v/v1.0.0.zip: 502 Bad Gateway", true},
+		{"reading https://proxy.golang.org/example.com/tool/@v/v1.0.0.zip: 404 Not Found", false},
+		{"main.go:3:2: undefined: foo", false},
+		{"verifying example.com/tool@v1.0.0: checksum mismatch\n\tdownloaded: h1:abc\n\tgo.sum:     h1:def\n\nSECURITY ERROR\nunexpected EOF", false},
+		{"", false},
+	} {
+		if got := transient([]byte(tt.out)); got != tt.want {
+			t.Errorf("transient(%q) = %t, want %t", tt.out, got, tt.want)
+		}
+	}
+}
+
+func TestThrottled(t *testing.T) {
+	for _, tt := range []struct {
+		msg  string
+		want bool
+	}{
+		{"reading https://proxy.golang.org/foo/@v/list: 429 Too Many Requests", true},
+		{"reading https://proxy.golang.org/foo/@v/list: 503 Service Unavailable", true},
+		{"reading https://proxy.golang.org/foo/@v/list: 404 Not Found", false},
+		{"reading https://proxy.golang.org/foo/@v/list: 410 Gone", false},
+		{"module foo: no matching versions for query \"latest\"", false},
+	} {
+		if got := throttled(tt.msg); got != tt.want {
+			t.Errorf("throttled(%q) = %t, want %t", tt.msg, got, tt.want)
+		}
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	for _, tt := range []struct {
+		attempt int
+		max     time.Duration
+	}{
+		{2, 500 * time.Millisecond},
+		{3, time.Second},
+		{4, 2 * time.Second},
+		{6, 8 * time.Second},
+		{10, 8 * time.Second},
+	} {
+		for i := 0; i < 100; i++ {
+			if d := backoff(tt.attempt); d <= tt.max/2 || d > tt.max {
+				t.Errorf("backoff(%d) = %s, want in (%s, %s]", tt.attempt, d, tt.max/2, tt.max)
+				break
+			}
+		}
+	}
+}
+
+// acquireNow from t, or report that it would have blocked.
+func acquireNow(t *throttle) (func(), bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	release, err := t.acquire(ctx)
+	return release, err == nil
+}
+
+func TestThrottle(t *testing.T) {
+	th := newThrottle(4)
+	var held []func()
+	for i := 0; i < 4; i++ {
+		release, ok := acquireNow(th)
+		if !ok {
+			t.Fatalf("acquire %d of 4 blocked", i+1)
+		}
+		held = append(held, release)
+	}
+	if _, ok := acquireNow(th); ok {
+		t.Fatal("acquired a 5th of 4")
+	}
+
+	// Turned away: halved, down to no fewer than 1.
+	th.refused()
+	th.refused()
+	th.refused()
+	for _, release := range held[1:] {
+		release()
+	}
+	held = held[:1]
+	if _, ok := acquireNow(th); ok {
+		t.Fatal("acquired a 2nd of 1 after being turned away")
+	}
+
+	// Let through: back up by one.
+	th.passed()
+	release, ok := acquireNow(th)
+	if !ok {
+		t.Fatal("acquire of 2 blocked after a lookup let through")
+	}
+	held = append(held, release)
+	if _, ok := acquireNow(th); ok {
+		t.Fatal("acquired a 3rd of 2")
+	}
+
+	// Never past max.
+	for i := 0; i < 10; i++ {
+		th.passed()
+	}
+	if th.limit != 4 {
+		t.Errorf("limit %d after passing, want max 4", th.limit)
+	}
+	for _, release := range held {
+		release()
+	}
+}
+
+func TestThrottleWakes(t *testing.T) {
+	th := newThrottle(1)
+	release, ok := acquireNow(th)
+	if !ok {
+		t.Fatal("acquire blocked")
+	}
+	acquired := make(chan struct{})
+	go func() {
+		release, err := th.acquire(context.Background())
+		if err == nil {
+			release()
+		}
+		close(acquired)
+	}()
+	select {
+	case <-acquired:
+		t.Fatal("acquired a 2nd of 1")
+	case <-time.After(10 * time.Millisecond):
+	}
+	release()
+	select {
+	case <-acquired:
+	case <-time.After(time.Second):
+		t.Fatal("waiting acquire not woken by release")
+	}
+}
@@ -0,0 +1,58 @@
+package golatest
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// backup copies file next to it, so that a failed install can be undone.
+// The copy is not executable until restored, lest it be taken for a
+// program of its own. It returns a func restoring file from the copy, and
+// one removing the copy, neither of which do anything if there was no file.
+func backup(file string) (restore func() error, remove func(), err error) {
+	bak, fi, err := copyTemp(file, "."+filepath.Base(file)+".backup-*")
+	if errors.Is(err, fs.ErrNotExist) {
+		return func() error { return nil }, func() {}, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	restore = func() error {
+		err := os.Chmod(bak, fi.Mode().Perm())
+		if err != nil {
+			return err
+		}
+		return os.Rename(bak, file)
+	}
+	return restore, func() { os.Remove(bak) }, nil
+}
+
+// copyTemp copies file to a new file in the same directory, named after
+// pattern like os.CreateTemp does, returning its name and the info of file.
+func copyTemp(file, pattern string) (string, fs.FileInfo, error) {
+	src, err := os.Open(file)
+	if err != nil {
+		return "", nil, err
+	}
+	defer src.Close()
+	fi, err := src.Stat()
+	if err != nil {
+		return "", nil, err
+	}
+	dst, err := os.CreateTemp(filepath.Dir(file), pattern)
+	if err != nil {
+		return "", nil, err
+	}
+	_, err = io.Copy(dst, src)
+	if cerr := dst.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(dst.Name())
+		return "", nil, err
+	}
+	return dst.Name(), fi, nil
+}
@@ -0,0 +1,111 @@
+package golatest
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// files in dir, by name.
+func files(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	return names
+}
+
+func TestBackupRestore(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "tool")
+	err := os.WriteFile(file, []byte("v1"), 0o755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	restore, remove, err := backup(file)
+	if err != nil {
+		t.Fatalf("backup: %v", err)
+	}
+	defer remove()
+	if got := files(t, dir); len(got) != 2 {
+		t.Fatalf("got files %q, want tool and its backup", got)
+	}
+
+	// A failed install replacing the program.
+	err = os.WriteFile(file, []byte("broken"), 0o755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := restore(); err != nil {
+		t.Fatalf("restore: %v", err)
+	}
+	b, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "v1" {
+		t.Errorf("restored %q, want v1", b)
+	}
+	if runtime.GOOS != "windows" {
+		fi, err := os.Stat(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fi.Mode().Perm() != 0o755 {
+			t.Errorf("restored mode %s, want -rwxr-xr-x", fi.Mode())
+		}
+	}
+	if got := files(t, dir); len(got) != 1 {
+		t.Errorf("got files %q after restore, want tool alone", got)
+	}
+}
+
+func TestBackupRemove(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "tool")
+	err := os.WriteFile(file, []byte("v1"), 0o755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, remove, err := backup(file)
+	if err != nil {
+		t.Fatalf("backup: %v", err)
+	}
+	if runtime.GOOS != "windows" {
+		for _, name := range files(t, dir) {
+			fi, err := os.Stat(filepath.Join(dir, name))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if name != "tool" && fi.Mode().Perm()&0o111 != 0 {
+				t.Errorf("backup %s is executable: %s", name, fi.Mode())
+			}
+		}
+	}
+	remove()
+	if got := files(t, dir); len(got) != 1 || got[0] != "tool" {
+		t.Errorf("got files %q after remove, want tool alone", got)
+	}
+}
+
+func TestBackupNoFile(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "tool")
+	restore, remove, err := backup(file)
+	if err != nil {
+		t.Fatalf("backup: %v", err)
+	}
+	if err := restore(); err != nil {
+		t.Errorf("restore: %v", err)
+	}
+	remove()
+	if got := files(t, dir); len(got) != 0 {
+		t.Errorf("got files %q, want none", got)
+	}
+}
@@ -0,0 +1,142 @@
+package golatest
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Cache of the latest versions of modules on disk, for lookups within TTL
+// of the last to skip go list, e.g.
+//
+//	{"golang.org/x/tools/gopls": {"version": "v0.15.1", "released": "2024-02-27T19:10:00Z", "time": "2024-03-02T12:00:00Z"}}
+//
+// Lookups with prereleases are keyed apart, by a "+pre" suffix, and
+// vulnerability lookups by an "osv/" prefix.
+type Cache struct {
+	file string
+	ttl  time.Duration
+
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+	changed bool
+}
+
+type cacheEntry struct {
+	Version  string     `json:"version,omitempty"`
+	Released *time.Time `json:"released,omitempty"`
+	// Deprecated notice of the module, as of Version.
+	Deprecated string `json:"deprecated,omitempty"`
+	// Data of lookups other than the latest version.
+	Data json.RawMessage `json:"data,omitempty"`
+	Time time.Time       `json:"time"`
+}
+
+// DefaultCacheFile in the user cache directory, or empty if there is none.
+func DefaultCacheFile() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "go-latest", "latest.json")
+}
+
+// OpenCache in file, with entries expiring after ttl.
+// A missing file is an empty cache, written by Save.
+func OpenCache(file string, ttl time.Duration) (*Cache, error) {
+	c := &Cache{file: file, ttl: ttl, entries: map[string]cacheEntry{}}
+	buf, err := os.ReadFile(file)
+	if errors.Is(err, fs.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	// A cache which doesn't parse is as good as empty.
+	json.Unmarshal(buf, &c.entries)
+	return c, nil
+}
+
+// Save the cache to its file, if anything changed.
+func (c *Cache) Save() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.changed {
+		return nil
+	}
+	for key, e := range c.entries {
+		if time.Since(e.Time) > c.ttl {
+			delete(c.entries, key)
+		}
+	}
+	buf, err := json.Marshal(c.entries)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(c.file), 0o755)
+	if err != nil {
+		return err
+	}
+	// Renamed into place, lest a concurrent run read half of it.
+	tmp := c.file + ".tmp"
+	err = os.WriteFile(tmp, buf, 0o644)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, c.file)
+}
+
+// get the latest version of key, when it was released and whether it is
+// deprecated, if looked up within the ttl.
+func (c *Cache) get(key string) (lookup, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok || time.Since(e.Time) > c.ttl {
+		return lookup{}, false
+	}
+	l := lookup{version: e.Version, deprecated: e.Deprecated}
+	if e.Released != nil {
+		l.released = *e.Released
+	}
+	return l, true
+}
+
+// put the latest version of key, as of now.
+func (c *Cache) put(key string, l lookup) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e := cacheEntry{Version: l.version, Deprecated: l.deprecated, Time: time.Now()}
+	if !l.released.IsZero() {
+		e.Released = &l.released
+	}
+	c.entries[key] = e
+	c.changed = true
+}
+
+// getData of key into v, if looked up within the ttl.
+func (c *Cache) getData(key string, v any) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok || e.Data == nil || time.Since(e.Time) > c.ttl {
+		return false
+	}
+	return json.Unmarshal(e.Data, v) == nil
+}
+
+// putData v of key, as of now.
+func (c *Cache) putData(key string, v any) {
+	buf, err := json.Marshal(v)
+	if err != nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[key] = cacheEntry{Data: buf, Time: time.Now()}
+	c.changed = true
+}
@@ -0,0 +1,140 @@
+package golatest
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCacheSave(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "go-latest", "latest.json")
+	c, err := OpenCache(file, time.Hour)
+	if err != nil {
+		t.Fatalf("OpenCache of no file: %v", err)
+	}
+	if err := c.Save(); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if _, err := os.Stat(file); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Save unchanged wrote %s: %v", file, err)
+	}
+
+	released := time.Date(2024, 2, 27, 19, 10, 0, 0, time.UTC)
+	c.put("golang.org/x/tools/gopls", lookup{version: "v0.15.1", released: released})
+	c.put("example.com/old", lookup{version: "v1.0.0", deprecated: "use example.com/new"})
+	c.put("example.com/tool+pre", lookup{version: "v1.1.0-rc.1"})
+	c.putData("osv/example.com/tool", []string{"GO-2024-0001"})
+	if err := c.Save(); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	c, err = OpenCache(file, time.Hour)
+	if err != nil {
+		t.Fatalf("OpenCache: %v", err)
+	}
+	for _, tt := range []struct {
+		key  string
+		want lookup
+	}{
+		{"golang.org/x/tools/gopls", lookup{version: "v0.15.1", released: released}},
+		{"example.com/old", lookup{version: "v1.0.0", deprecated: "use example.com/new"}},
+		{"example.com/tool+pre", lookup{version: "v1.1.0-rc.1"}},
+	} {
+		got, ok := c.get(tt.key)
+		if !ok {
+			t.Errorf("get %s after Save: not found", tt.key)
+			continue
+		}
+		if got.version != tt.want.version || !got.released.Equal(tt.want.released) || got.deprecated != tt.want.deprecated {
+			t.Errorf("get %s = %+v, want %+v", tt.key, got, tt.want)
+		}
+	}
+	if _, ok := c.get("example.com/tool"); ok {
+		t.Errorf("get example.com/tool found the +pre entry")
+	}
+	var ids []string
+	if !c.getData("osv/example.com/tool", &ids) || len(ids) != 1 || ids[0] != "GO-2024-0001" {
+		t.Errorf("getData osv/example.com/tool = %q, want GO-2024-0001", ids)
+	}
+}
+
+// cacheFile of entries, encoded the way Save does.
+func cacheFile(t *testing.T, entries map[string]cacheEntry) string {
+	t.Helper()
+	buf, err := json.Marshal(entries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(t.TempDir(), "latest.json")
+	if err := os.WriteFile(file, buf, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func TestCacheTTL(t *testing.T) {
+	now := time.Now()
+	file := cacheFile(t, map[string]cacheEntry{
+		"example.com/fresh":     {Version: "v1.0.0", Time: now.Add(-time.Minute)},
+		"example.com/stale":     {Version: "v1.0.0", Time: now.Add(-2 * time.Hour)},
+		"osv/example.com/stale": {Data: json.RawMessage(`[]`), Time: now.Add(-2 * time.Hour)},
+	})
+	c, err := OpenCache(file, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := c.get("example.com/fresh"); !ok {
+		t.Errorf("get of an entry within the ttl: not found")
+	}
+	if _, ok := c.get("example.com/stale"); ok {
+		t.Errorf("get of an entry past the ttl: found")
+	}
+	var ids []string
+	if c.getData("osv/example.com/stale", &ids) {
+		t.Errorf("getData of an entry past the ttl: found")
+	}
+
+	// Saving drops what expired.
+	c.put("example.com/new", lookup{version: "v2.0.0"})
+	if err := c.Save(); err != nil {
+		t.Fatal(err)
+	}
+	buf, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var saved map[string]cacheEntry
+	if err := json.Unmarshal(buf, &saved); err != nil {
+		t.Fatal(err)
+	}
+	if len(saved) != 2 || saved["example.com/fresh"].Version == "" || saved["example.com/new"].Version == "" {
+		t.Errorf("saved %s, want the fresh and new entries alone", buf)
+	}
+
+	// A shorter ttl next time expires more.
+	c, err = OpenCache(file, 30*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := c.get("example.com/fresh"); ok {
+		t.Errorf("get of a minute old entry with a ttl of 30s: found")
+	}
+}
+
+func TestCacheCorrupt(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "latest.json")
+	if err := os.WriteFile(file, []byte("{not json"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	c, err := OpenCache(file, time.Hour)
+	if err != nil {
+		t.Fatalf("OpenCache of a corrupt file: %v", err)
+	}
+	if _, ok := c.get("example.com/tool"); ok {
+		t.Errorf("get from a corrupt cache: found")
+	}
+}
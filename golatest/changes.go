@@ -0,0 +1,57 @@
+package golatest
+
+import (
+	"strings"
+
+	"golang.org/x/mod/module"
+)
+
+// vanity import paths of repositories hosted elsewhere, known without
+// fetching the go-import meta tag.
+var vanity = map[string]string{
+	"golang.org/x/": "github.com/golang/",
+}
+
+// changesURL comparing versions from and to of module mod, e.g.
+// https://github.com/owner/repo/compare/v1.2.0...v1.3.0, or empty
+// if it isn't on a host known to have one.
+func changesURL(mod, from, to string) string {
+	for prefix, repo := range vanity {
+		if strings.HasPrefix(mod, prefix) {
+			mod = repo + strings.TrimPrefix(mod, prefix)
+			break
+		}
+	}
+	prefix, _, _ := module.SplitPathVersion(mod)
+	parts := strings.SplitN(prefix, "/", 4)
+	if len(parts) < 3 {
+		return ""
+	}
+	repo := strings.Join(parts[:3], "/")
+	var compare string
+	switch parts[0] {
+	case "github.com":
+		compare = "/compare/"
+	case "gitlab.com":
+		compare = "/-/compare/"
+	default:
+		return ""
+	}
+	// Modules in a subdirectory of the repo are tagged with it.
+	var dir string
+	if len(parts) == 4 {
+		dir = parts[3] + "/"
+	}
+	return "https://" + repo + compare + ref(dir, from) + "..." + ref(dir, to)
+}
+
+// ref in the repo of version v of a module in dir, a tag or a commit.
+func ref(dir, v string) string {
+	if module.IsPseudoVersion(v) {
+		rev, err := module.PseudoVersionRev(v)
+		if err == nil {
+			return rev
+		}
+	}
+	return dir + strings.TrimSuffix(v, "+incompatible")
+}
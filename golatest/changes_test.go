@@ -0,0 +1,28 @@
+package golatest
+
+import "testing"
+
+func TestChangesURL(t *testing.T) {
+	for _, tt := range []struct {
+		mod, from, to string
+		want          string
+	}{
+		{"github.com/owner/repo", "v1.2.0", "v1.3.0", "https://github.com/owner/repo/compare/v1.2.0...v1.3.0"},
+		{"gitlab.com/owner/repo", "v1.2.0", "v1.3.0", "https://gitlab.com/owner/repo/-/compare/v1.2.0...v1.3.0"},
+		{"github.com/owner/repo/v2", "v2.0.0", "v2.1.0", "https://github.com/owner/repo/compare/v2.0.0...v2.1.0"},
+		{"github.com/owner/repo/tools/cmd", "v0.1.0", "v0.2.0", "https://github.com/owner/repo/compare/tools/cmd/v0.1.0...tools/cmd/v0.2.0"},
+		{"github.com/owner/repo/sub/v3", "v3.0.0", "v3.0.1", "https://github.com/owner/repo/compare/sub/v3.0.0...sub/v3.0.1"},
+		{"golang.org/x/tools/gopls", "v0.15.0", "v0.15.1", "https://github.com/golang/tools/compare/gopls/v0.15.0...gopls/v0.15.1"},
+		{"golang.org/x/vuln", "v1.0.0", "v1.0.1", "https://github.com/golang/vuln/compare/v1.0.0...v1.0.1"},
+		{"github.com/owner/repo", "v2.0.0+incompatible", "v3.0.0+incompatible", "https://github.com/owner/repo/compare/v2.0.0...v3.0.0"},
+		{"github.com/owner/repo", "v0.0.0-20240102030405-abcdefabcdef", "v0.1.0", "https://github.com/owner/repo/compare/abcdefabcdef...v0.1.0"},
+		{"github.com/owner/repo/sub", "v1.2.4-0.20240102030405-abcdefabcdef", "v1.3.0", "https://github.com/owner/repo/compare/abcdefabcdef...sub/v1.3.0"},
+		{"github.com/owner", "v1.0.0", "v1.1.0", ""},
+		{"example.com/tool", "v1.0.0", "v1.1.0", ""},
+		{"bitbucket.org/owner/repo", "v1.0.0", "v1.1.0", ""},
+	} {
+		if got := changesURL(tt.mod, tt.from, tt.to); got != tt.want {
+			t.Errorf("changesURL(%q, %q, %q) = %q, want %q", tt.mod, tt.from, tt.to, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,18 @@
+// Package golatest upgrades programs go install-d to GOBIN, the way the
+// go-latest command does:
+//
+//	u := golatest.New(golatest.Options{Workers: 4})
+//	results, err := u.Upgrade(ctx)
+//
+// For reference, go itself:
+// ./src/cmd/go/internal/version/version.go
+// ./src/cmd/go/internal/work/build.go
+package golatest
+
+// NOTES:
+// golang.org/x/mod/semver
+// golang.org/x/mod/module
+//
+// Use:
+// go list -m -json golang.org/x/tools/gopls@latest
+// either on each pkg or on the module.
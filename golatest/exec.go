@@ -0,0 +1,199 @@
+package golatest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Runner of go commands, to run them some other way than exec does, e.g.
+// with canned output.
+type Runner interface {
+	// Run go with args, env set on top of the inherited environment,
+	// returning what it wrote to stdout and stderr.
+	Run(ctx context.Context, env []string, args ...string) (stdout, stderr []byte, err error)
+}
+
+// execRunner runs go commands with exec, the default Runner.
+type execRunner struct{}
+
+// Run go with args.
+// When ctx is done, go is killed along with any compilers or linkers it
+// started, where the platform allows it.
+func (execRunner) Run(ctx context.Context, env []string, args ...string) ([]byte, []byte, error) {
+	cmd := command(ctx, env, args...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	err := cmd.Run()
+	return stdout.Bytes(), stderr.Bytes(), err
+}
+
+// runTo is Run with stdout and stderr both written to out as they come.
+func (execRunner) runTo(ctx context.Context, env []string, out io.Writer, args ...string) error {
+	cmd := command(ctx, env, args...)
+	// The same writer for both, for a single pipe in the order written.
+	cmd.Stdout, cmd.Stderr = out, out
+	return cmd.Run()
+}
+
+// command running go with args, as Run does.
+func command(ctx context.Context, env []string, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, "go", args...)
+	if len(env) > 0 {
+		// Later entries take precedence.
+		cmd.Env = append(os.Environ(), env...)
+	}
+	killGroup(cmd)
+	// Don't hang on output pipes held open by stragglers.
+	cmd.WaitDelay = 5 * time.Second
+	return cmd
+}
+
+// runner of go commands for an Upgrader.
+type runner struct {
+	Runner
+	// env set for every go command, on top of the inherited environment.
+	env []string
+	// proxy is the module cache as GOPROXY, when offline.
+	proxy string
+	// trace, if set, gets each go command echoed to it as it's started,
+	// like go -x does.
+	trace io.Writer
+	// output, if set, gets the output of the go commands run by
+	// goStreamed as it's written.
+	output io.Writer
+	mu     sync.Mutex
+}
+
+// goCmd runs go with args and env set on top of those of the runner.
+func (r *runner) goCmd(ctx context.Context, env []string, args ...string) (stdout, stderr []byte, err error) {
+	env = append(r.env[:len(r.env):len(r.env)], env...)
+	if r.trace != nil {
+		r.traceCmd(env, args)
+	}
+	return r.Run(ctx, env, args...)
+}
+
+// goCombined is goCmd with stderr following stdout, like
+// exec.Cmd.CombinedOutput.
+func (r *runner) goCombined(ctx context.Context, env []string, args ...string) ([]byte, error) {
+	stdout, stderr, err := r.goCmd(ctx, env, args...)
+	return append(stdout, stderr...), err
+}
+
+// goStreamed is goCombined, also writing each line of the output to the
+// output of the runner, if any, after the name of what it's for in
+// brackets. Only execRunner writes it as it comes, other Runners all at
+// once when done.
+func (r *runner) goStreamed(ctx context.Context, env []string, name string, args ...string) ([]byte, error) {
+	if r.output == nil {
+		return r.goCombined(ctx, env, args...)
+	}
+	w := &prefixWriter{w: r.output, mu: &r.mu, prefix: "[" + name + "] "}
+	defer w.flush()
+	exe, ok := r.Runner.(execRunner)
+	if !ok {
+		out, err := r.goCombined(ctx, env, args...)
+		w.Write(out)
+		return out, err
+	}
+	env = append(r.env[:len(r.env):len(r.env)], env...)
+	if r.trace != nil {
+		r.traceCmd(env, args)
+	}
+	var out bytes.Buffer
+	err := exe.runTo(ctx, env, io.MultiWriter(&out, w), args...)
+	return out.Bytes(), err
+}
+
+// prefixWriter writes whole lines to w, each after prefix, holding mu
+// while it does so that lines of concurrent commands don't interleave.
+type prefixWriter struct {
+	w      io.Writer
+	mu     *sync.Mutex
+	prefix string
+	// partial line written so far.
+	partial []byte
+}
+
+func (p *prefixWriter) Write(b []byte) (int, error) {
+	p.partial = append(p.partial, b...)
+	for {
+		i := bytes.IndexByte(p.partial, '\n')
+		if i < 0 {
+			break
+		}
+		p.line(p.partial[:i+1])
+		p.partial = p.partial[i+1:]
+	}
+	return len(b), nil
+}
+
+// flush the last line, if it did not end in a newline.
+func (p *prefixWriter) flush() {
+	if len(p.partial) > 0 {
+		p.line(append(p.partial, '\n'))
+		p.partial = nil
+	}
+}
+
+func (p *prefixWriter) line(l []byte) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	// A single write, like traceCmd.
+	p.w.Write(append([]byte(p.prefix), l...))
+}
+
+// traceCmd to trace as a line to paste into a shell, after a "+ ",
+// e.g. cd /tmp/123 && GOPRIVATE='corp.example.com/*' go list -m foo@latest
+func (r *runner) traceCmd(env, args []string) {
+	var b strings.Builder
+	b.WriteString("+ ")
+	wd, err := os.Getwd()
+	if err == nil {
+		b.WriteString("cd " + shellQuote(wd) + " && ")
+	}
+	for _, kv := range env {
+		k, v, _ := strings.Cut(kv, "=")
+		b.WriteString(k + "=" + shellQuote(v) + " ")
+	}
+	b.WriteString("go")
+	for _, arg := range args {
+		b.WriteString(" " + shellQuote(arg))
+	}
+	b.WriteString("\n")
+
+	// A single write, lest lines of concurrent commands interleave.
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	io.WriteString(r.trace, b.String())
+}
+
+// shellQuote s if a shell would otherwise take it apart.
+func shellQuote(s string) string {
+	if s != "" && strings.Trim(s, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_=+.,/:@%") == "" {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// goEnv values of keys, as go env reports them.
+func (r *runner) goEnv(ctx context.Context, keys ...string) (map[string]string, error) {
+	out, _, err := r.goCmd(ctx, nil, append([]string{"env", "-json"}, keys...)...)
+	if err != nil {
+		return nil, fmt.Errorf("go env (%w)", err)
+	}
+	env := map[string]string{}
+	err = json.Unmarshal(out, &env)
+	if err != nil {
+		return nil, fmt.Errorf("go env: %v", err)
+	}
+	return env, nil
+}
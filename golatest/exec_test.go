@@ -0,0 +1,144 @@
+package golatest
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"sync"
+	"testing"
+)
+
+// writes recorded one by one.
+type writes []string
+
+func (w *writes) Write(b []byte) (int, error) {
+	*w = append(*w, string(b))
+	return len(b), nil
+}
+
+func TestPrefixWriter(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		chunks []string
+		want   []string
+	}{
+		{"lines", []string{"a\nb\n"}, []string{"[x] a\n", "[x] b\n"}},
+		{"split line", []string{"go: down", "loading\n"}, []string{"[x] go: downloading\n"}},
+		{"split after newline", []string{"a\n", "b\n"}, []string{"[x] a\n", "[x] b\n"}},
+		{"empty line", []string{"a\n\nb\n"}, []string{"[x] a\n", "[x] \n", "[x] b\n"}},
+		{"no newline at the end", []string{"a\nb"}, []string{"[x] a\n", "[x] b\n"}},
+		{"nothing", nil, nil},
+	} {
+		var got writes
+		p := &prefixWriter{w: &got, mu: &sync.Mutex{}, prefix: "[x] "}
+		for _, c := range tt.chunks {
+			if n, err := p.Write([]byte(c)); n != len(c) || err != nil {
+				t.Errorf("%s: Write(%q) = %d, %v", tt.name, c, n, err)
+			}
+		}
+		p.flush()
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%s: wrote %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestPrefixWriterFlush(t *testing.T) {
+	var got writes
+	p := &prefixWriter{w: &got, mu: &sync.Mutex{}, prefix: "[x] "}
+	p.Write([]byte("partial"))
+	if len(got) != 0 {
+		t.Errorf("wrote %q before the line ended", got)
+	}
+	p.flush()
+	p.flush()
+	if want := []string{"[x] partial\n"}; !slices.Equal(got, want) {
+		t.Errorf("wrote %q, want %q once", got, want)
+	}
+}
+
+func TestPrefixWriterConcurrent(t *testing.T) {
+	var (
+		mu  sync.Mutex
+		got writes
+		wg  sync.WaitGroup
+	)
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			p := &prefixWriter{w: &got, mu: &mu, prefix: fmt.Sprintf("[%d] ", i)}
+			for j := 0; j < 100; j++ {
+				// A line in two writes, to interleave if it could.
+				p.Write([]byte("line "))
+				p.Write([]byte(fmt.Sprintf("%d\n", j)))
+			}
+		}(i)
+	}
+	wg.Wait()
+	if len(got) != 400 {
+		t.Fatalf("got %d lines, want 400", len(got))
+	}
+	for _, l := range got {
+		var i, j int
+		if _, err := fmt.Sscanf(l, "[%d] line %d\n", &i, &j); err != nil {
+			t.Errorf("interleaved line %q", l)
+		}
+	}
+}
+
+func TestGoStreamed(t *testing.T) {
+	var out writes
+	r := &runner{
+		Runner: fakeRunner(func(_ []string, args ...string) ([]byte, []byte, error) {
+			return []byte("go: downloading example.com/tool v1.1.0\n"), []byte("build failed"), fmt.Errorf("exit status 1")
+		}),
+		output: &out,
+	}
+	got, err := r.goStreamed(context.Background(), nil, "tool", "install", "example.com/tool@v1.1.0")
+	if err == nil {
+		t.Errorf("goStreamed: nil error, want exit status 1")
+	}
+	if want := "go: downloading example.com/tool v1.1.0\nbuild failed"; string(got) != want {
+		t.Errorf("goStreamed output %q, want %q", got, want)
+	}
+	if want := (writes{"[tool] go: downloading example.com/tool v1.1.0\n", "[tool] build failed\n"}); !slices.Equal(out, want) {
+		t.Errorf("streamed %q, want %q", out, want)
+	}
+}
+
+func TestShellQuote(t *testing.T) {
+	for _, tt := range []struct {
+		s, want string
+	}{
+		{"golang.org/x/tools/gopls@latest", "golang.org/x/tools/gopls@latest"},
+		{"-ldflags=-s", "-ldflags=-s"},
+		{"/home/me/go/bin", "/home/me/go/bin"},
+		{"", "''"},
+		{"corp.example.com/*", "'corp.example.com/*'"},
+		{"-s -w", "'-s -w'"},
+		{"it's", `'it'\''s'`},
+		{"$HOME", "'$HOME'"},
+		{"a;b", "'a;b'"},
+		{"~", "'~'"},
+	} {
+		if got := shellQuote(tt.s); got != tt.want {
+			t.Errorf("shellQuote(%q) = %s, want %s", tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestTraceCmd(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var trace writes
+	r := &runner{trace: &trace}
+	r.traceCmd([]string{"GOPRIVATE=corp.example.com/*", "GOFLAGS="}, []string{"list", "-m", "example.com/tool@latest"})
+	want := writes{"+ cd " + shellQuote(wd) + " && GOPRIVATE='corp.example.com/*' GOFLAGS='' go list -m example.com/tool@latest\n"}
+	if !slices.Equal(trace, want) {
+		t.Errorf("traced %q, want %q", trace, want)
+	}
+}
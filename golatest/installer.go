@@ -0,0 +1,1142 @@
+package golatest
+
+import (
+	"bytes"
+	"context"
+	"debug/buildinfo"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
+	"golang.org/x/sync/errgroup"
+)
+
+// Actions taken on a program.
+const (
+	ActionSkip      = "skip"
+	ActionLatest    = "latest"
+	ActionUpgrade   = "upgrade"
+	ActionReinstall = "reinstall"
+	ActionInstall   = "install"
+	ActionConflict  = "conflict"
+	ActionDeclined  = "declined"
+	// ActionHeld upgrades fix no known vulnerability, see
+	// Options.OnlyVulnerable.
+	ActionHeld     = "held"
+	ActionOrphaned = "orphaned"
+	ActionRemoved  = "removed"
+	ActionPlanned  = "planned"
+	ActionError    = "error"
+)
+
+// Kinds of events passed to Options.Events as a run goes on.
+const (
+	// EventResolvePhase starts with the Total number of programs to resolve.
+	EventResolvePhase = "resolve"
+	EventResolved     = "resolved"
+	// EventInstallPhase starts with the Total number of programs to install.
+	EventInstallPhase = "install"
+	EventInstalling   = "installing"
+	EventInstalled    = "installed"
+)
+
+// Event in a run, either about a phase of it or a single program.
+type Event struct {
+	Kind string
+	// Total for phase events.
+	Total int
+	// Result so far for program events.
+	Result Result
+}
+
+// Result of processing a single program in GOBIN.
+// The command renders its tables, JSON and other formats from these.
+type Result struct {
+	// File of the program, empty for tools that could not be installed.
+	File string
+	// Path of the package it was built from.
+	Path string
+	// Module providing it, which may have moved since it was built.
+	Module string
+	// Current version, before any upgrade.
+	Current string
+	// Latest version, the one installed by an upgrade.
+	Latest string
+	// Released is when Latest was, if looked up and the proxy says.
+	Released time.Time
+	// GoCurrent built the program, GoLatest rebuilds it when that is
+	// why it's upgraded.
+	GoCurrent, GoLatest string
+	// Action taken, one of the Action constants.
+	Action string
+	// Err of a failed action.
+	Err error
+	// Lookup is how long go list took to find Latest, if looked up.
+	Lookup time.Duration
+	// Attempts at installing it, when it took more than one.
+	Attempts int
+	// Download is how long go mod download took to fetch Latest ahead of
+	// the install, shared by all modules in its batch.
+	Download time.Duration
+	// Duration of the install, if any.
+	Duration time.Duration
+	// Retracted is why Current was retracted, if it was.
+	Retracted string
+	// Deprecated is the deprecation notice of Module as of Latest, if any.
+	Deprecated string
+	// Revision and RevisionTime of the commit Current was built from, and
+	// whether the checkout was Modified, when built with VCS stamping.
+	Revision, RevisionTime string
+	Modified               bool
+	// Local builds, from a checkout or with the main module replaced,
+	// are not to be had from a proxy, so they are always skipped.
+	Local bool
+	// Skipped is why it was, for some skips, e.g. "newer than latest
+	// v1.2.0".
+	Skipped string
+	// SizeBefore and SizeAfter of the installed file, in bytes, when
+	// installed. Zero before means there was none.
+	SizeBefore, SizeAfter int64
+	// Changes links to what changed from Current to Latest, for upgrades
+	// of modules hosted where that is known.
+	Changes string
+	// Versions released from Current to Latest, for upgrades when asked
+	// for with Options.Changelog.
+	Versions []string
+	// Vulns known of Current, when looked up, or VulnErr why they are
+	// not known.
+	Vulns   []Vuln
+	VulnErr error
+}
+
+func (r Result) MarshalJSON() ([]byte, error) {
+	var errMsg, released, vulnErr string
+	if r.Err != nil {
+		errMsg = r.Err.Error()
+	}
+	if r.VulnErr != nil {
+		vulnErr = r.VulnErr.Error()
+	}
+	type vuln struct {
+		Vuln
+		FixedByLatest bool `json:"fixed_by_latest,omitempty"`
+	}
+	var vulns []vuln
+	for _, v := range r.Vulns {
+		vulns = append(vulns, vuln{v, r.Fixes(v)})
+	}
+	if !r.Released.IsZero() {
+		released = r.Released.Format(time.RFC3339)
+	}
+	return json.Marshal(struct {
+		File       string   `json:"file,omitempty"`
+		Path       string   `json:"path"`
+		Module     string   `json:"module,omitempty"`
+		Current    string   `json:"current,omitempty"`
+		Latest     string   `json:"latest,omitempty"`
+		Released   string   `json:"released,omitempty"`
+		GoCurrent  string   `json:"go_current,omitempty"`
+		GoLatest   string   `json:"go_latest,omitempty"`
+		Action     string   `json:"action"`
+		Error      string   `json:"error,omitempty"`
+		LookupMS   int64    `json:"lookup_ms,omitempty"`
+		DownloadMS int64    `json:"download_ms,omitempty"`
+		DurationMS int64    `json:"duration_ms,omitempty"`
+		Attempts   int      `json:"attempts,omitempty"`
+		Retracted  string   `json:"retracted,omitempty"`
+		Deprecated string   `json:"deprecated,omitempty"`
+		Revision   string   `json:"vcs_revision,omitempty"`
+		Time       string   `json:"vcs_time,omitempty"`
+		Modified   bool     `json:"vcs_modified,omitempty"`
+		Local      bool     `json:"local,omitempty"`
+		Skipped    string   `json:"skipped,omitempty"`
+		SizeBefore int64    `json:"size_before,omitempty"`
+		SizeAfter  int64    `json:"size_after,omitempty"`
+		Changes    string   `json:"changes,omitempty"`
+		Versions   []string `json:"versions,omitempty"`
+		Vulns      []vuln   `json:"vulns,omitempty"`
+		VulnErr    string   `json:"vuln_error,omitempty"`
+	}{
+		File:       r.File,
+		Path:       r.Path,
+		Module:     r.Module,
+		Current:    r.Current,
+		Latest:     r.Latest,
+		Released:   released,
+		GoCurrent:  r.GoCurrent,
+		GoLatest:   r.GoLatest,
+		Action:     r.Action,
+		Error:      errMsg,
+		LookupMS:   r.Lookup.Milliseconds(),
+		DownloadMS: r.Download.Milliseconds(),
+		DurationMS: r.Duration.Milliseconds(),
+		Attempts:   r.Attempts,
+		Retracted:  r.Retracted,
+		Deprecated: r.Deprecated,
+		Revision:   r.Revision,
+		Time:       r.RevisionTime,
+		Modified:   r.Modified,
+		Local:      r.Local,
+		Skipped:    r.Skipped,
+		SizeBefore: r.SizeBefore,
+		SizeAfter:  r.SizeAfter,
+		Changes:    r.Changes,
+		Versions:   r.Versions,
+		Vulns:      vulns,
+		VulnErr:    vulnErr,
+	})
+}
+
+// Pending upgrade of a program which has been resolved but not yet installed.
+type Pending struct {
+	Result *Result
+	// Neither means a forced reinstall.
+	goUpgrade, modUpgrade bool
+	// flags and env the program was originally built with.
+	flags, env []string
+	// changes and versions from the installed version, see
+	// Result.Changes and Result.Versions.
+	changes  string
+	versions []string
+}
+
+// Options for an Upgrader.
+type Options struct {
+	// Dir to upgrade the programs in, GOBIN if empty.
+	Dir string
+	// Programs to upgrade by file name, e.g. gopls, all those in Dir if
+	// empty. Naming one that is not there fails the upgrade.
+	Programs []string
+	// Workers installing programs at once, one if not positive.
+	Workers int
+	// LookupWorkers resolving programs, and running go list, at once,
+	// Workers if not positive. Lookups wait on the network rather than
+	// compete for the CPU, so there can be more of them.
+	LookupWorkers int
+	// LookupTimeout of each go list looking up versions, tried again up
+	// to twice when it runs out, DefaultLookupTimeout if zero and none if
+	// negative.
+	LookupTimeout time.Duration
+	// LatestGo re-installs programs not built with the local toolchain.
+	LatestGo bool
+	// ToolchainOnly re-installs those at the version already installed,
+	// without looking up the latest. It implies LatestGo.
+	ToolchainOnly bool
+	// Force re-installs programs which are already latest.
+	Force bool
+	// ForceAll re-installs programs at specific versions as well.
+	ForceAll bool
+	// Pre upgrades to prereleases.
+	Pre bool
+	// PatchOnly upgrades to the latest patch release of the minor version
+	// installed, passing over newer minor and major versions.
+	PatchOnly bool
+	// SameMajor upgrades to the latest release of the major version
+	// installed, passing over newer major versions.
+	SameMajor bool
+	// Changelog looks up the versions released between those installed
+	// and the latest of upgrades, see Result.Versions.
+	Changelog bool
+	// AllowDowngrade to a latest older than what is installed, rather than
+	// keeping it.
+	AllowDowngrade bool
+	// Sync installs the tools from the manifest which are missing, if set.
+	Sync *Manifest
+	// Plan to apply, if set, installing the versions pinned in it rather
+	// than the latest and leaving other programs be.
+	Plan *Manifest
+	// Confirm is asked before each upgrade, if set.
+	Confirm func(what string) bool
+	// Choose which of the resolved upgrades to install, if set.
+	Choose func(ctx context.Context, ups []*Pending) ([]*Pending, error)
+	// Events, if set, is told what happens as it happens.
+	// It may be called concurrently.
+	Events func(e Event)
+	// RemoveOrphaned programs without confirmation.
+	RemoveOrphaned bool
+	// Offline looks up and installs only what is in the module cache.
+	Offline bool
+	// Vulns looks up the known vulnerabilities of the modules programs
+	// were built with, in the OSV API at VulnDB or DefaultVulnDB.
+	Vulns  bool
+	VulnDB string
+	// OnlyVulnerable upgrades only programs with a vulnerability in their
+	// main module that the upgrade fixes, holding back the rest.
+	// Nothing is upgraded if vulnerabilities can't be looked up.
+	// It implies Vulns.
+	OnlyVulnerable bool
+	// IgnoreSettings of the original builds, reinstalling with defaults.
+	IgnoreSettings bool
+	// DryRun resolves but installs nothing, planning upgrades instead.
+	DryRun bool
+	// Cache of latest versions to look up first, and add to, if set.
+	// Saving it is up to the caller.
+	Cache *Cache
+	// MaxAge skips programs installed more recently, going by the
+	// modification time of their file, if positive.
+	MaxAge time.Duration
+	// PerHost limits the go commands fetching from any one host at a time,
+	// if positive.
+	PerHost int
+	// PerModule limits the upgrades installing from any one module at a
+	// time, if positive, as they contend for its files in the module cache.
+	PerModule int
+	// MaxQPS limits the go commands querying the proxy for versions, and
+	// downloading modules ahead of installs, to as many a second, if
+	// positive. Retries count too.
+	MaxQPS float64
+	// Env set for every go command, as KEY=value on top of the inherited
+	// environment.
+	Env []string
+	// Runner of the go commands, exec if nil.
+	Runner Runner
+	// Trace, if set, gets each go command echoed to it as it's started,
+	// like go -x does.
+	Trace io.Writer
+	// Output, if set, gets the output of each go install as it's written,
+	// each line after the name of the program in brackets, e.g.
+	// "[gopls] go: downloading golang.org/x/tools v0.20.0".
+	Output io.Writer
+	// Log of what goes on, e.g. a retracted version installed, with the
+	// path, module and versions of programs as attributes. Nothing is
+	// logged if nil, the Results tell what was done either way.
+	Log *slog.Logger
+}
+
+func (o Options) event(kind string, res *Result) {
+	if o.Events != nil {
+		o.Events(Event{Kind: kind, Result: *res})
+	}
+}
+
+func (o Options) phase(kind string, total int) {
+	if o.Events != nil {
+		o.Events(Event{Kind: kind, Total: total})
+	}
+}
+
+// Upgrader of the programs in a directory, see New.
+type Upgrader struct {
+	opts Options
+	run  *runner
+	log  *slog.Logger
+}
+
+// New Upgrader with opts.
+func New(opts Options) *Upgrader {
+	u := &Upgrader{opts: opts, run: &runner{Runner: opts.Runner, env: opts.Env, trace: opts.Trace, output: opts.Output}, log: opts.Log}
+	if u.run.Runner == nil {
+		u.run.Runner = execRunner{}
+	}
+	if u.log == nil {
+		u.log = slog.New(discard{})
+	}
+	if u.opts.Workers < 1 {
+		u.opts.Workers = 1
+	}
+	if u.opts.LookupWorkers < 1 {
+		u.opts.LookupWorkers = u.opts.Workers
+	}
+	if u.opts.LookupTimeout == 0 {
+		u.opts.LookupTimeout = DefaultLookupTimeout
+	}
+	return u
+}
+
+// discard is a slog.Handler of no records at all.
+type discard struct{}
+
+func (discard) Enabled(context.Context, slog.Level) bool  { return false }
+func (discard) Handle(context.Context, slog.Record) error { return nil }
+func (d discard) WithAttrs([]slog.Attr) slog.Handler      { return d }
+func (d discard) WithGroup(string) slog.Handler           { return d }
+
+// GoEnv values of keys, as go env run with r reports them, exec if nil,
+// with the command echoed to trace if set.
+func GoEnv(ctx context.Context, r Runner, trace io.Writer, keys ...string) (map[string]string, error) {
+	if r == nil {
+		r = execRunner{}
+	}
+	run := &runner{Runner: r, trace: trace}
+	return run.goEnv(ctx, keys...)
+}
+
+// vulns of the programs described by infos, from the OSV API.
+func (u *Upgrader) vulns(ctx context.Context, infos []*buildinfo.BuildInfo) ([][]Vuln, error) {
+	if u.opts.Offline {
+		return nil, errors.New("offline")
+	}
+	db := &vulnDB{url: u.opts.VulnDB, client: http.DefaultClient, cache: u.opts.Cache, workers: u.opts.LookupWorkers}
+	if db.url == "" {
+		db.url = DefaultVulnDB
+	}
+	return db.vulns(ctx, infos)
+}
+
+// offline makes go commands look up modules in the module cache alone,
+// laid out just like a proxy of what has been downloaded before.
+func (u *Upgrader) offline(ctx context.Context) error {
+	env, err := u.run.goEnv(ctx, "GOMODCACHE")
+	if err != nil {
+		return err
+	}
+	u.run.proxy = fileURL(filepath.Join(env["GOMODCACHE"], "cache", "download"))
+	// Its sums were checked when downloaded.
+	u.run.env = append(u.opts.Env[:len(u.opts.Env):len(u.opts.Env)], "GOPROXY="+u.run.proxy, "GOSUMDB=off")
+	return nil
+}
+
+// fileURL of the absolute path, as GOPROXY takes it.
+func fileURL(path string) string {
+	path = filepath.ToSlash(path)
+	if !strings.HasPrefix(path, "/") {
+		// A Windows drive, as in file:///C:/Users.
+		path = "/" + path
+	}
+	return "file://" + path
+}
+
+// Upgrade the programs, returning results by package path and then file.
+// All programs are resolved before any of them is installed.
+func (u *Upgrader) Upgrade(ctx context.Context) ([]Result, error) {
+	opts, log := u.opts, u.log
+	dir := opts.Dir
+	if dir == "" {
+		dir = GOBIN()
+	}
+	if dir == "" {
+		return nil, errors.New("GOBIN not found")
+	}
+	progs, err := listPrograms(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var goVersion string
+	// Check against the local toolchain version of Go since that is
+	// what we're going to use to install programs.
+	if opts.LatestGo || opts.ToolchainOnly {
+		goVersion, err = goversion(ctx, u.run)
+		if err != nil {
+			return nil, fmt.Errorf("go version: %w", err)
+		}
+	}
+
+	var missing []Tool
+	// Results of tools that are not going to be installed.
+	var notInstalled []Result
+	switch {
+	case opts.Plan != nil:
+		missing, notInstalled = missingTools(opts.Plan, progs)
+	case opts.Sync != nil:
+		missing, notInstalled = missingTools(opts.Sync, progs)
+	}
+	if len(opts.Programs) > 0 {
+		// Only now, as missing tools of the manifest are those not there
+		// at all.
+		progs, err = named(dir, progs, opts.Programs)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if opts.Offline {
+		err = u.offline(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var infos []*buildinfo.BuildInfo
+	var goProgs []string
+	for _, f := range progs {
+		info, err := readBuildInfo(f)
+		if err != nil {
+			// E.g. a shell script, which only matters to a manifest
+			// naming a tool like it, as a conflict.
+			log.Debug("not a Go program, skipping it", "file", f, "err", err)
+			continue
+		}
+		infos = append(infos, info)
+		goProgs = append(goProgs, f)
+	}
+	progs = goProgs
+
+	// Look up the latest versions of all modules at once, rather than
+	// running go list for each program.
+	// Programs installed within MaxAge are left be without a lookup.
+	recent := make([]bool, len(progs))
+	var mods, installed []string
+	for i, f := range progs {
+		if opts.MaxAge > 0 {
+			fi, err := os.Stat(f)
+			recent[i] = err == nil && time.Since(fi.ModTime()) < opts.MaxAge
+		}
+		if opts.Plan == nil && !opts.ToolchainOnly && !recent[i] && (!isSpecific(infos[i].Main.Version) || opts.ForceAll) {
+			mods = append(mods, modulePath(infos[i]))
+		}
+		if semver.IsValid(infos[i].Main.Version) && semver.Build(infos[i].Main.Version) == "" {
+			installed = append(installed, modulePath(infos[i])+"@"+infos[i].Main.Version)
+		}
+	}
+	opts.phase(EventResolvePhase, len(progs))
+	hosts := newHostLimit(opts.PerHost)
+	cache := opts.Cache
+	if opts.Offline {
+		// What was latest online need not be in the module cache.
+		cache = nil
+	}
+	lookups := newResolver(opts.Pre, opts.Offline, opts.LookupWorkers, u.run, hosts, cache)
+	lookups.timeout, lookups.log = opts.LookupTimeout, log
+	lookups.rate = newRateLimit(opts.MaxQPS)
+	lookups.prefetch(ctx, mods)
+	// Even programs pinned to a version are worth a warning.
+	retracted := lookups.retractions(ctx, installed)
+	var vulns [][]Vuln
+	var vulnErr error
+	if opts.Vulns || opts.OnlyVulnerable {
+		vulns, vulnErr = u.vulns(ctx, infos)
+		if vulnErr != nil && opts.OnlyVulnerable {
+			return nil, fmt.Errorf("vulnerabilities unknown, upgrading nothing: %w", vulnErr)
+		}
+		if vulnErr != nil {
+			log.Warn("vulnerability status unknown", "err", vulnErr)
+		}
+	}
+	if opts.OnlyVulnerable {
+		// Nothing installed has any vulnerabilities to fix.
+		for _, t := range missing {
+			notInstalled = append(notInstalled, Result{Path: t.Path, Latest: t.Version, Action: ActionHeld})
+		}
+		missing = nil
+	}
+
+	var eg errgroup.Group
+	eg.SetLimit(opts.LookupWorkers)
+
+	// Each worker fills in its own slot.
+	results := make([]Result, len(progs)+len(missing), len(progs)+len(missing)+len(notInstalled))
+	resolved := make([]*Pending, len(progs))
+	for i, f := range progs {
+		i := i
+		results[i].File = f
+		if opts.Vulns || opts.OnlyVulnerable {
+			results[i].VulnErr = vulnErr
+			if vulns != nil {
+				results[i].Vulns = vulns[i]
+			}
+		}
+		eg.Go(func() error {
+			defer opts.event(EventResolved, &results[i])
+			resolved[i] = u.resolve(ctx, log, lookups, goVersion, retracted, recent[i], infos[i], &results[i])
+			return nil
+		})
+	}
+	err = eg.Wait()
+	if err != nil {
+		return nil, err
+	}
+
+	var ups []*Pending
+	for _, up := range resolved {
+		if up != nil {
+			ups = append(ups, up)
+		}
+	}
+	if opts.Choose != nil {
+		chosen, err := opts.Choose(ctx, ups)
+		if err != nil {
+			return nil, err
+		}
+		ok := map[*Pending]bool{}
+		for _, up := range chosen {
+			ok[up] = true
+		}
+		for _, up := range ups {
+			if !ok[up] {
+				up.Result.Action = ActionDeclined
+			}
+		}
+		ups = chosen
+	}
+	if opts.DryRun {
+		for _, up := range ups {
+			up.Result.Action = ActionPlanned
+			up.Result.Changes = up.changes
+			up.Result.Versions = up.versions
+		}
+		for i, t := range missing {
+			res := Result{
+				File:   filepath.Join(dir, binaryName(t.Path)),
+				Path:   t.Path,
+				Module: t.Module,
+				Latest: t.Version,
+				Action: ActionPlanned,
+			}
+			if res.Latest == "" {
+				// Pin it for a plan.
+				mod, l := lookups.latest(ctx, t.Path, t.Path)
+				res.Module, res.Latest, res.Released, res.Lookup = mod, l.version, l.released, l.took
+				if l.err != nil {
+					res.Action, res.Err = ActionError, l.err
+				}
+			}
+			results[len(progs)+i] = res
+		}
+		ups, missing = nil, nil
+	}
+
+	if len(ups) > 1 {
+		u.download(ctx, log, hosts, lookups, ups)
+	}
+	log.Debug("queried the proxy", "go_commands", lookups.queries.Load())
+	opts.phase(EventInstallPhase, len(ups)+len(missing))
+	var installs errgroup.Group
+	installs.SetLimit(opts.Workers)
+	ups, copies := dedupe(ups)
+	modules := newModuleLimit(opts.PerModule)
+	for _, up := range ups {
+		up := up
+		installs.Go(func() error {
+			u.install(ctx, hosts, modules, up)
+			for _, c := range copies[up] {
+				u.copy(up, c)
+			}
+			return nil
+		})
+	}
+	for i, t := range missing {
+		tt := t
+		res := &results[len(progs)+i]
+		installs.Go(func() error {
+			opts.event(EventInstalling, res)
+			*res = installTool(ctx, u.run, hosts, dir, tt)
+			opts.event(EventInstalled, res)
+			return nil
+		})
+	}
+	err = installs.Wait()
+	if err != nil {
+		return nil, err
+	}
+	results = append(results, notInstalled...)
+	// Workers finish in any order. The same package may be installed
+	// under several names, e.g. copied binaries, so break ties by file.
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Path != results[j].Path {
+			return results[i].Path < results[j].Path
+		}
+		return results[i].File < results[j].File
+	})
+	return results, nil
+}
+
+// resolve the program in res.File built as described by info,
+// returning the upgrade to install if any. Recent ones are skipped.
+func (u *Upgrader) resolve(ctx context.Context, log *slog.Logger, lookups *resolver, goVersion string, retracted map[string]string, recent bool, info *buildinfo.BuildInfo, res *Result) *Pending {
+	opts := u.opts
+	res.built(info)
+	log = log.With(
+		"path", info.Path,
+		"module", res.Module,
+		"current", info.Main.Version,
+	)
+	res.Retracted = retracted[res.Module+"@"+res.Current]
+	if res.Retracted != "" {
+		log.Warn("installed version is retracted", "rationale", res.Retracted)
+	}
+	if res.Local {
+		log.Debug("local build, skipping it", "why", localBuild(info))
+		res.Action = ActionSkip
+		return nil
+	}
+	if isSpecific(info.Main.Version) && !opts.ForceAll || recent {
+		res.Action = ActionSkip
+		return nil
+	}
+
+	var mod string
+	var l lookup
+	if opts.Plan != nil {
+		t, ok := opts.Plan.pinned(info.Path)
+		if !ok {
+			res.Action = ActionSkip
+			return nil
+		}
+		mod, l.version = res.Module, t.Version
+		if t.Module != "" {
+			mod = t.Module
+		}
+	} else if opts.ToolchainOnly {
+		// Rebuilt as is, only by another Go.
+		mod, l.version = res.Module, info.Main.Version
+	} else {
+		// Latest available is checked per module.
+		mod, l = lookups.latest(ctx, res.Module, info.Path)
+	}
+	target, err := l.version, l.err
+	res.Lookup = l.took
+	if err == nil && mod != res.Module {
+		// E.g. golang.org/x/tools/cmd/auth/authtest, built from
+		// golang.org/x/tools but since moved to a module of its own.
+		log.Debug("found in other module", "other", mod)
+		res.Module = mod
+	}
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil
+		}
+		// Installing @latest blind would only fail again, or worse succeed
+		// with a version we can't report.
+		res.Action = ActionError
+		res.Err = err
+		return nil
+	}
+	if l.retracted != "" {
+		log.Warn("newest version is retracted, passing it over", "retracted", l.retracted, "rationale", l.rationale)
+	}
+	res.Deprecated = l.deprecated
+	if res.Deprecated != "" {
+		log.Warn("module is deprecated", "notice", res.Deprecated)
+	}
+	if opts.PatchOnly && res.Module == modulePath(info) && semver.IsValid(info.Main.Version) &&
+		semver.MajorMinor(target) != semver.MajorMinor(info.Main.Version) && semver.Compare(target, info.Main.Version) > 0 {
+		log.Info("newer minor version available, passing it over for patches only", "available", target)
+		l = lookups.within(ctx, res.Module, info.Main.Version, semver.MajorMinor(info.Main.Version))
+		if l.err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			res.Action = ActionError
+			res.Err = l.err
+			return nil
+		}
+		target = l.version
+		res.Lookup += l.took
+	}
+	if opts.SameMajor && res.Module == modulePath(info) && semver.IsValid(info.Main.Version) &&
+		semver.Major(target) != semver.Major(info.Main.Version) && semver.Compare(target, info.Main.Version) > 0 {
+		// E.g. v0 to v1, or v2.0.0+incompatible to v3.0.0+incompatible,
+		// as other majors are at other module paths otherwise.
+		log.Info("newer major version available, passing it over for the same major only", "available", target)
+		l = lookups.within(ctx, res.Module, info.Main.Version, semver.Major(info.Main.Version))
+		if l.err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			res.Action = ActionError
+			res.Err = l.err
+			return nil
+		}
+		target = l.version
+		res.Lookup += l.took
+	}
+	if module.IsPseudoVersion(info.Main.Version) && !opts.ForceAll && !opts.ToolchainOnly &&
+		(module.IsPseudoVersion(target) || res.Module == modulePath(info) && semver.Compare(target, info.Main.Version) <= 0) {
+		// Specific SHA, e.g. v0.0.0-20240102150405-abcdef123456, kept
+		// until there is a release tagged after it. Rebuilt at it by
+		// another Go all the same.
+		log.Debug("no release after pseudo-version, skipping it", "latest", target)
+		res.Action = ActionSkip
+		return nil
+	}
+	// Newer than latest, e.g. a prerelease ahead of the latest release,
+	// kept rather than downgraded.
+	var newer string
+	if res.Module == modulePath(info) && semver.Compare(target, info.Main.Version) < 0 && !opts.AllowDowngrade {
+		if semver.Prerelease(info.Main.Version) == "" {
+			log.Warn("latest is older than installed, not downgrading", "latest", target)
+		}
+		newer = "newer than latest " + target
+		target = info.Main.Version
+	} else {
+		res.Released = l.released
+	}
+	res.Latest = target
+	log = log.With("latest", target)
+
+	goUpgrade := (opts.LatestGo || opts.ToolchainOnly) && goVersion != info.GoVersion
+	if goUpgrade {
+		res.GoLatest = goVersion
+	}
+	// Versions of different modules don't compare.
+	modUpgrade := target != info.Main.Version || res.Module != modulePath(info)
+	if !(opts.Force || goUpgrade || modUpgrade) {
+		res.Action = ActionLatest
+		if newer != "" {
+			res.Action, res.Skipped = ActionSkip, newer
+		}
+		return nil
+	}
+	if opts.OnlyVulnerable && len(res.fixed()) == 0 {
+		res.Action = ActionHeld
+		return nil
+	}
+
+	var flags, env []string
+	if !opts.IgnoreSettings {
+		flags, env = buildFlags(info), buildEnv(info)
+	}
+
+	// Lookups carry on in other workers while this one waits for an answer.
+	if opts.Confirm != nil && !opts.Confirm(fmt.Sprintf("%s %s -> %s", info.Path, info.Main.Version, target)) {
+		res.Action = ActionDeclined
+		return nil
+	}
+	up := &Pending{
+		Result:     res,
+		goUpgrade:  goUpgrade,
+		modUpgrade: modUpgrade,
+		flags:      flags,
+		env:        env,
+	}
+	if res.Module == modulePath(info) && target != info.Main.Version {
+		up.changes = changesURL(res.Module, info.Main.Version, target)
+		if opts.Changelog && semver.IsValid(info.Main.Version) {
+			// Nice to know, not worth failing the upgrade for.
+			vs, err := lookups.between(ctx, res.Module, info.Main.Version, target)
+			if err != nil && ctx.Err() == nil {
+				log.Debug("versions unknown", "err", err)
+			}
+			up.versions = vs
+		}
+	}
+	return up
+}
+
+// download the modules of ups ahead of installing them, as many at once
+// as lookups, since installs are held back by the CPU rather than the
+// network. Failures are left for the installs to report.
+func (u *Upgrader) download(ctx context.Context, log *slog.Logger, hosts *keyLimit, lookups *resolver, ups []*Pending) {
+	byVersion := map[string][]*Result{}
+	var mvs []string
+	for _, up := range ups {
+		mv := up.Result.Module + "@" + up.Result.Latest
+		if byVersion[mv] == nil {
+			mvs = append(mvs, mv)
+		}
+		byVersion[mv] = append(byVersion[mv], up.Result)
+	}
+	var eg errgroup.Group
+	eg.SetLimit(u.opts.LookupWorkers)
+	for _, batch := range batches(mvs) {
+		batch := batch
+		eg.Go(func() error {
+			release, err := hosts.acquire(ctx, batch[0])
+			if err != nil {
+				return nil
+			}
+			defer release()
+			start := time.Now()
+			_, stderr, err := lookups.goCmd(ctx, append([]string{"mod", "download"}, batch...)...)
+			took := time.Since(start)
+			if err != nil && ctx.Err() == nil {
+				log.Debug("download failed, leaving it to go install", "modules", strings.Join(batch, " "), "err", fmt.Errorf("%w:\n%s", err, stderr))
+			}
+			for _, mv := range batch {
+				for _, res := range byVersion[mv] {
+					res.Download = took
+				}
+			}
+			return nil
+		})
+	}
+	eg.Wait()
+}
+
+// install a resolved upgrade, recording the outcome in its Result.
+func (u *Upgrader) install(ctx context.Context, hosts, modules *keyLimit, up *Pending) {
+	opts := u.opts
+	res := up.Result
+	release, err := modules.acquire(ctx, res.Module)
+	if err != nil {
+		return
+	}
+	defer release()
+	release, err = hosts.acquire(ctx, res.Module)
+	if err != nil {
+		return
+	}
+	defer release()
+	opts.event(EventInstalling, res)
+	defer opts.event(EventInstalled, res)
+
+	// Install the module version we resolved, rather than @latest which
+	// could have moved in the meantime. For a command nested in its
+	// module, e.g. golang.org/x/tools/cmd/stringer, that is the version
+	// of golang.org/x/tools.
+	// TODO: Is it faster to combine packages from the same module into a single exec?
+	args := append([]string{"install"}, up.flags...)
+	// Where go install puts it, unless it has been renamed.
+	installed, env := res.File, up.env
+	if filepath.Base(res.File) != binaryName(res.Path) {
+		// With no copy named like go install names it to copy from, it
+		// is installed to a directory of its own and renamed over the
+		// file, rather than written next to it under the other name.
+		tmp, err := os.MkdirTemp(filepath.Dir(res.File), ".go-latest-")
+		if err != nil {
+			res.Action = ActionError
+			res.Err = fmt.Errorf("install: %w", err)
+			return
+		}
+		defer os.RemoveAll(tmp)
+		installed = filepath.Join(tmp, binaryName(res.Path))
+		env = append(env[:len(env):len(env)], "GOBIN="+tmp)
+	}
+	if fi, err := os.Stat(res.File); err == nil {
+		res.SizeBefore = fi.Size()
+	}
+	// Whatever go install leaves behind when it fails, the program
+	// installed before is put back.
+	restore, removeBackup, err := backup(res.File)
+	if err != nil {
+		res.Action = ActionError
+		res.Err = fmt.Errorf("backup: %w", err)
+		return
+	}
+	start := time.Now()
+	var out []byte
+	attempt := 1
+	for ; ; attempt++ {
+		out, err = u.run.goStreamed(ctx, env, filepath.Base(res.File), append(args, res.Path+"@"+res.Latest)...)
+		if err == nil || attempt == installAttempts || !transient(out) || sleep(ctx, backoff(attempt+1)) != nil {
+			break
+		}
+	}
+	res.Duration = time.Since(start)
+	if attempt > 1 {
+		res.Attempts = attempt
+	}
+	if err != nil && attempt > 1 {
+		err = fmt.Errorf("%w, after %d attempts", err, attempt)
+	}
+	if err != nil {
+		restoreErr := restore()
+		if ctx.Err() != nil {
+			return
+		}
+		if restoreErr != nil {
+			res.Action = ActionError
+			res.Err = fmt.Errorf("go install (%s), then restoring %s failed: %v:\n%s", err, res.File, restoreErr, out)
+			return
+		}
+		if isOrphaned(out) {
+			orphaned(opts, res, res.Latest)
+			return
+		}
+		res.Action = ActionError
+		res.Err = fmt.Errorf("go install (%s):\n%s", err, out)
+		if opts.Offline && bytes.Contains(out, []byte(u.run.proxy)) {
+			res.Err = fmt.Errorf("go install: %s@%s not in the module cache (%s):\n%s", res.Path, res.Latest, err, out)
+		}
+		return
+	}
+	if installed != res.File {
+		err = os.Rename(installed, res.File)
+		if err != nil {
+			// Left as it was.
+			removeBackup()
+			res.Action = ActionError
+			res.Err = fmt.Errorf("install: %w", err)
+			return
+		}
+	}
+	removeBackup()
+	if fi, err := os.Stat(res.File); err == nil {
+		res.SizeAfter = fi.Size()
+	}
+	if !(up.goUpgrade || up.modUpgrade) {
+		res.Action = ActionReinstall
+		return
+	}
+	res.Action = ActionUpgrade
+	res.Changes = up.changes
+	res.Versions = up.versions
+	// TODO: If deprecated, ask if remove?
+}
+
+// dedupe ups of the same program under several names, e.g. copied or
+// hard linked, to install each once. The one named like go install names
+// it, if any, is installed, the others become copies of it.
+func dedupe(ups []*Pending) ([]*Pending, map[*Pending][]*Pending) {
+	seen := map[string]*Pending{}
+	var keys []string
+	byKey := map[string][]*Pending{}
+	for _, up := range ups {
+		res := up.Result
+		key := fmt.Sprint(filepath.Dir(res.File), res.Path, res.Latest, up.goUpgrade, up.flags, up.env)
+		if seen[key] == nil {
+			keys = append(keys, key)
+		}
+		if seen[key] == nil || filepath.Base(res.File) == binaryName(res.Path) {
+			seen[key] = up
+		}
+		byKey[key] = append(byKey[key], up)
+	}
+	var once []*Pending
+	copies := map[*Pending][]*Pending{}
+	for _, key := range keys {
+		up := seen[key]
+		once = append(once, up)
+		for _, c := range byKey[key] {
+			if c != up {
+				copies[up] = append(copies[up], c)
+			}
+		}
+	}
+	return once, copies
+}
+
+// copy the outcome of installing up to c, another name of the same program,
+// along with the program itself if it was installed.
+func (u *Upgrader) copy(up, c *Pending) {
+	res := c.Result
+	u.opts.event(EventInstalling, res)
+	defer u.opts.event(EventInstalled, res)
+	res.Action, res.Err, res.Duration = up.Result.Action, up.Result.Err, up.Result.Duration
+	if res.Action != ActionUpgrade && res.Action != ActionReinstall {
+		return
+	}
+	installed := up.Result.File
+	if fi, err := os.Stat(res.File); err == nil {
+		res.SizeBefore = fi.Size()
+	}
+	// Written next to it and renamed into place, the program is never
+	// half copied, and hard links to it are broken rather than written to.
+	tmp, fi, err := copyTemp(installed, "."+filepath.Base(res.File)+".copy-*")
+	if err == nil {
+		err = os.Chmod(tmp, fi.Mode().Perm())
+		if err == nil {
+			err = os.Rename(tmp, res.File)
+		}
+		if err != nil {
+			os.Remove(tmp)
+		}
+	}
+	if err != nil {
+		res.Action = ActionError
+		res.Err = fmt.Errorf("copy %s: %w", installed, err)
+		return
+	}
+	res.SizeAfter = fi.Size()
+	// Copies need not have been at the same version.
+	if !(c.goUpgrade || c.modUpgrade) {
+		res.Action = ActionReinstall
+		return
+	}
+	res.Action = ActionUpgrade
+	res.Changes, res.Versions = c.changes, c.versions
+}
+
+// built fills in res as described by info, the program as it was built.
+func (res *Result) built(info *buildinfo.BuildInfo) {
+	res.Path = info.Path
+	res.Module = modulePath(info)
+	res.Current = info.Main.Version
+	res.GoCurrent = info.GoVersion
+	res.Local = localBuild(info) != ""
+	// None with -buildvcs=false, or outside of a checkout.
+	for _, s := range info.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			res.Revision = s.Value
+		case "vcs.time":
+			res.RevisionTime = s.Value
+		case "vcs.modified":
+			res.Modified = s.Value == "true"
+		}
+	}
+}
+
+// localBuild tells why the program described by info was built locally,
+// e.g. "built from a checkout at 1a2b3c4", or is empty if it wasn't.
+// Installing from the proxy replaces nothing, nor stamps a checkout.
+func localBuild(info *buildinfo.BuildInfo) string {
+	for _, s := range info.Settings {
+		if s.Key == "vcs.revision" {
+			return "built from a checkout at " + s.Value[:min(len(s.Value), 7)]
+		}
+	}
+	if r := info.Main.Replace; r != nil {
+		return "main module replaced by " + r.Path
+	}
+	for _, d := range info.Deps {
+		if d.Replace != nil {
+			return "dependency " + d.Path + " replaced by " + d.Replace.Path
+		}
+	}
+	if info.Main.Version == "(devel)" {
+		return "built from a checkout"
+	}
+	return ""
+}
+
+// buildFlags to build the program described by info the same way again.
+// The -X flags of -ldflags are dropped, they tend to stamp the version
+// being replaced.
+func buildFlags(info *buildinfo.BuildInfo) []string {
+	var flags []string
+	for _, s := range info.Settings {
+		switch s.Key {
+		case "-tags":
+			if s.Value != "" {
+				flags = append(flags, "-tags="+s.Value)
+			}
+		case "-trimpath":
+			if s.Value == "true" {
+				flags = append(flags, "-trimpath")
+			}
+		case "-ldflags":
+			var ldflags []string
+			fields := strings.Fields(s.Value)
+			for i := 0; i < len(fields); i++ {
+				switch f := fields[i]; {
+				case f == "-X" || f == "--X":
+					i++
+				case strings.HasPrefix(f, "-X=") || strings.HasPrefix(f, "--X="):
+				default:
+					ldflags = append(ldflags, f)
+				}
+			}
+			if len(ldflags) > 0 {
+				flags = append(flags, "-ldflags="+strings.Join(ldflags, " "))
+			}
+		}
+	}
+	return flags
+}
+
+// buildEnv to build the program described by info for the same platform
+// again, e.g. CGO_ENABLED=0. A program cross-compiled for another platform
+// is built for this one, as go install refuses to cross-compile to GOBIN.
+func buildEnv(info *buildinfo.BuildInfo) []string {
+	cross := false
+	for _, s := range info.Settings {
+		if s.Key == "GOOS" && s.Value != runtime.GOOS || s.Key == "GOARCH" && s.Value != runtime.GOARCH {
+			cross = true
+		}
+	}
+	var env []string
+	for _, s := range info.Settings {
+		switch s.Key {
+		case "CGO_ENABLED":
+			env = append(env, s.Key+"="+s.Value)
+		case "GOOS", "GOARCH",
+			"GO386", "GOAMD64", "GOARM", "GOARM64", "GOMIPS", "GOMIPS64", "GOPPC64", "GORISCV64", "GOWASM":
+			if !cross {
+				env = append(env, s.Key+"="+s.Value)
+			}
+		}
+	}
+	return env
+}
@@ -0,0 +1,711 @@
+package golatest
+
+import (
+	"bytes"
+	"context"
+	"debug/buildinfo"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeRunner runs go commands by calling itself, e.g. with canned output.
+type fakeRunner func(env []string, args ...string) (stdout, stderr []byte, err error)
+
+func (f fakeRunner) Run(_ context.Context, env []string, args ...string) ([]byte, []byte, error) {
+	return f(env, args...)
+}
+
+// noGo is a fakeRunner failing the test on any go command.
+func noGo(t *testing.T) fakeRunner {
+	return func(_ []string, args ...string) ([]byte, []byte, error) {
+		t.Errorf("unexpected go %s", strings.Join(args, " "))
+		return nil, nil, fmt.Errorf("unexpected go %s", strings.Join(args, " "))
+	}
+}
+
+func TestUpgradeNotGo(t *testing.T) {
+	dir := t.TempDir()
+	err := os.WriteFile(filepath.Join(dir, "tool"), []byte("#!/bin/sh\necho not go\n"), 0o755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, err := New(Options{
+		Dir:    dir,
+		Runner: noGo(t),
+		Sync:   &Manifest{Tools: []Tool{{Path: "example.com/tool"}}},
+	}).Upgrade(context.Background())
+	if err != nil {
+		t.Fatalf("Upgrade: %v", err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("got %d results, want 1: %+v", len(results), results)
+	}
+	r := results[0]
+	if r.Action != ActionConflict || r.Err == nil || !strings.Contains(r.Err.Error(), "is not a Go program") {
+		t.Errorf("got %s %v, want a conflict with a file that is not a Go program", r.Action, r.Err)
+	}
+}
+
+func TestListNotGo(t *testing.T) {
+	dir := t.TempDir()
+	err := os.WriteFile(filepath.Join(dir, "script"), []byte("#!/bin/sh\n"), 0o755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, err := List(dir)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(results) != 0 {
+		t.Errorf("got %+v, want no programs", results)
+	}
+}
+
+// built info of a program with build settings kvs, key then value.
+func built(kvs ...string) *buildinfo.BuildInfo {
+	info := &buildinfo.BuildInfo{}
+	for i := 0; i+1 < len(kvs); i += 2 {
+		info.Settings = append(info.Settings, debug.BuildSetting{Key: kvs[i], Value: kvs[i+1]})
+	}
+	return info
+}
+
+func TestBuildFlags(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		info *buildinfo.BuildInfo
+		want []string
+	}{
+		{"none", built(), nil},
+		{"defaults", built("-tags", "", "-trimpath", "false", "-ldflags", ""), nil},
+		{"tags", built("-tags", "netgo,osusergo"), []string{"-tags=netgo,osusergo"}},
+		{"trimpath", built("-trimpath", "true"), []string{"-trimpath"}},
+		{"ldflags", built("-ldflags", "-s -w"), []string{"-ldflags=-s -w"}},
+		{"version stamp", built("-ldflags", "-s -X main.version=v1.0.0 -w"), []string{"-ldflags=-s -w"}},
+		{"version stamps", built("-ldflags", "-X=main.version=v1.0.0 --X main.commit=abc --X=main.date=today"), nil},
+		{"all", built("-ldflags", "-s", "-tags", "netgo", "-trimpath", "true", "CGO_ENABLED", "0"),
+			[]string{"-ldflags=-s", "-tags=netgo", "-trimpath"}},
+	} {
+		if got := buildFlags(tt.info); !slices.Equal(got, tt.want) {
+			t.Errorf("%s: buildFlags = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestBuildEnv(t *testing.T) {
+	other := "windows"
+	if runtime.GOOS == other {
+		other = "linux"
+	}
+	for _, tt := range []struct {
+		name string
+		info *buildinfo.BuildInfo
+		want []string
+	}{
+		{"none", built(), nil},
+		{"host", built("CGO_ENABLED", "0", "GOOS", runtime.GOOS, "GOARCH", runtime.GOARCH, "-trimpath", "true"),
+			[]string{"CGO_ENABLED=0", "GOOS=" + runtime.GOOS, "GOARCH=" + runtime.GOARCH}},
+		{"host arch level", built("GOOS", runtime.GOOS, "GOARCH", runtime.GOARCH, "GOAMD64", "v3"),
+			[]string{"GOOS=" + runtime.GOOS, "GOARCH=" + runtime.GOARCH, "GOAMD64=v3"}},
+		{"cross os", built("CGO_ENABLED", "0", "GOOS", other, "GOARCH", runtime.GOARCH),
+			[]string{"CGO_ENABLED=0"}},
+		{"cross arch", built("GOOS", runtime.GOOS, "GOARCH", "mips", "GOMIPS", "softfloat"),
+			nil},
+	} {
+		if got := buildEnv(tt.info); !slices.Equal(got, tt.want) {
+			t.Errorf("%s: buildEnv = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestLocalBuild(t *testing.T) {
+	checkout := built("vcs", "git", "vcs.revision", "1a2b3c4d5e6f", "vcs.modified", "true")
+	checkout.Path, checkout.Main = "example.com/tool", debug.Module{Path: "example.com/tool", Version: "(devel)"}
+	for _, tt := range []struct {
+		name string
+		info *buildinfo.BuildInfo
+		want string
+	}{
+		{"installed", prog("example.com/tool", "example.com/tool", "v1.0.0"), ""},
+		{"pseudo-version", prog("example.com/tool", "example.com/tool", "v0.0.0-20240101000000-1a2b3c4d5e6f"), ""},
+		{"devel", prog("example.com/tool", "example.com/tool", "(devel)"), "built from a checkout"},
+		{"checkout", checkout, "built from a checkout at 1a2b3c4"},
+		{"short revision", built("vcs.revision", "1a2b"), "built from a checkout at 1a2b"},
+		{"replaced", &buildinfo.BuildInfo{Main: debug.Module{Path: "example.com/tool", Version: "v1.0.0", Replace: &debug.Module{Path: "../tool"}}}, "main module replaced by ../tool"},
+		{"dependency replaced", &buildinfo.BuildInfo{
+			Main: debug.Module{Path: "example.com/tool", Version: "v1.0.0"},
+			Deps: []*debug.Module{{Path: "example.com/dep", Version: "v1.0.0"}, {Path: "example.com/fork", Version: "v1.0.0", Replace: &debug.Module{Path: "example.com/fork/v2", Version: "v2.0.0"}}},
+		}, "dependency example.com/fork replaced by example.com/fork/v2"},
+	} {
+		if got := localBuild(tt.info); got != tt.want {
+			t.Errorf("%s: localBuild = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	// Skipped as such, not even looked up.
+	fakePrograms(t)
+	dir := t.TempDir()
+	program(t, filepath.Join(dir, "tool"), checkout)
+	g := &goInstall{t: t, dir: dir, list: &goList{}}
+	results, err := New(Options{Dir: dir, Runner: g}).Upgrade(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Action != ActionSkip || !results[0].Local {
+		t.Errorf("got %+v, want a local build skipped", results)
+	}
+	if len(g.list.runs) != 0 {
+		t.Errorf("listed %q, want nothing", g.list.runs)
+	}
+}
+
+func TestUpgradeEnv(t *testing.T) {
+	fakePrograms(t)
+	dir := t.TempDir()
+	info := prog("example.com/tool", "example.com/tool", "v1.0.0")
+	info.Settings = []debug.BuildSetting{{Key: "CGO_ENABLED", Value: "0"}}
+	program(t, filepath.Join(dir, "tool"), info)
+	g := &goInstall{t: t, dir: dir, list: &goList{latest: map[string]listing{
+		"example.com/tool": {Path: "example.com/tool", Version: "v1.1.0"},
+	}}}
+	env := []string{"GOPRIVATE=corp.example.com/*", "GOFLAGS=-mod=mod"}
+	_, err := New(Options{Dir: dir, Runner: g, Env: env}).Upgrade(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(g.list.envs) == 0 {
+		t.Errorf("ran no go list")
+	}
+	for _, got := range g.list.envs {
+		if !slices.Equal(got, env) {
+			t.Errorf("go list with env %q, want %q", got, env)
+		}
+	}
+	// Built as before, in the env of all go commands.
+	want := append(slices.Clone(env), "CGO_ENABLED=0")
+	if len(g.installs) != 1 || !slices.Equal(g.installs[0].env, want) {
+		t.Errorf("go install runs %+v, want one with env %q", g.installs, want)
+	}
+}
+
+func TestNilLog(t *testing.T) {
+	u := New(Options{})
+	if u.log.Enabled(context.Background(), slog.LevelError) {
+		t.Errorf("nil Log logs errors, want nothing logged")
+	}
+}
+
+// fakeProgram starts the files written by program, which fakePrograms has
+// readBuildInfo read as Go programs.
+const fakeProgram = "#!fake go program\n"
+
+// fakePrograms has readBuildInfo read the files written by program as Go
+// programs, and no others, for the rest of the test.
+func fakePrograms(t *testing.T) {
+	read := readBuildInfo
+	t.Cleanup(func() { readBuildInfo = read })
+	readBuildInfo = func(file string) (*buildinfo.BuildInfo, error) {
+		b, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		b, ok := bytes.CutPrefix(b, []byte(fakeProgram))
+		if !ok {
+			return nil, errors.New("not a Go executable")
+		}
+		info := &buildinfo.BuildInfo{}
+		return info, json.Unmarshal(b, info)
+	}
+}
+
+// prog is the info of pkg built of mod at version with go1.22.0.
+func prog(pkg, mod, version string) *buildinfo.BuildInfo {
+	return &buildinfo.BuildInfo{GoVersion: "go1.22.0", Path: pkg, Main: debug.Module{Path: mod, Version: version}}
+}
+
+// program written to file as built as described by info, see fakePrograms.
+func program(t *testing.T, file string, info *buildinfo.BuildInfo) {
+	t.Helper()
+	b, err := json.Marshal(info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(file, append([]byte(fakeProgram), b...), 0o755)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// goRun of go with args and env on top of the inherited environment.
+type goRun struct {
+	env, args []string
+}
+
+// goInstall is a Runner of go list -m as list runs it, go mod download,
+// go env GOVERSION answering goVersion, go env GOMODCACHE answering
+// modcache, and go install pkg@version writing the program built of the
+// module of pkg in mods, pkg itself if not there, to GOBIN as set last in
+// its env, or dir. Installs take delay, and each is recorded. With stderr,
+// every install fails with it.
+type goInstall struct {
+	t         *testing.T
+	list      *goList
+	dir       string
+	mods      map[string]string
+	goVersion string
+	modcache  string
+	delay     time.Duration
+	stderr    string
+
+	mu       sync.Mutex
+	installs []goRun
+}
+
+func (g *goInstall) Run(ctx context.Context, env []string, args ...string) ([]byte, []byte, error) {
+	switch {
+	case len(args) > 1 && args[0] == "list":
+		return g.list.Run(ctx, env, args...)
+	case len(args) > 1 && args[0] == "mod" && args[1] == "download":
+		return nil, nil, nil
+	case len(args) == 2 && args[0] == "env" && args[1] == "GOVERSION":
+		return []byte(g.goVersion + "\n"), nil, nil
+	case len(args) == 3 && args[0] == "env" && args[1] == "-json" && args[2] == "GOMODCACHE":
+		out, _ := json.Marshal(map[string]string{"GOMODCACHE": g.modcache})
+		return out, nil, nil
+	case len(args) < 2 || args[0] != "install":
+		g.t.Errorf("unexpected go %s", strings.Join(args, " "))
+		return nil, nil, fmt.Errorf("unexpected go %s", strings.Join(args, " "))
+	}
+	g.mu.Lock()
+	g.installs = append(g.installs, goRun{env, args})
+	g.mu.Unlock()
+	time.Sleep(g.delay)
+	if g.stderr != "" {
+		return nil, []byte(g.stderr), fmt.Errorf("exit status 1")
+	}
+
+	pkg, version, _ := strings.Cut(args[len(args)-1], "@")
+	mod := g.mods[pkg]
+	if mod == "" {
+		mod = pkg
+	}
+	gobin := g.dir
+	for _, kv := range env {
+		if v, ok := strings.CutPrefix(kv, "GOBIN="); ok {
+			gobin = v
+		}
+	}
+	info := prog(pkg, mod, version)
+	if g.goVersion != "" {
+		info.GoVersion = g.goVersion
+	}
+	program(g.t, filepath.Join(gobin, binaryName(pkg)), info)
+	return nil, nil, nil
+}
+
+// installed pkg@version by each install recorded, in order.
+func (g *goInstall) installed() []string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	var got []string
+	for _, run := range g.installs {
+		got = append(got, run.args[len(run.args)-1])
+	}
+	return got
+}
+
+// versions of the programs in dir, by name, those that are not Go programs
+// as such.
+func versions(t *testing.T, dir string) map[string]string {
+	t.Helper()
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]string{}
+	for _, f := range files {
+		info, err := readBuildInfo(filepath.Join(dir, f.Name()))
+		if err != nil {
+			got[f.Name()] = "not a Go program"
+			continue
+		}
+		got[f.Name()] = info.Main.Version
+	}
+	return got
+}
+
+func TestUpgradeRenamed(t *testing.T) {
+	fakePrograms(t)
+	dir := t.TempDir()
+	program(t, filepath.Join(dir, "tool-old"), prog("example.com/tool", "example.com/tool", "v1.0.0"))
+	// Named like go install would name the other, were it installed.
+	err := os.WriteFile(filepath.Join(dir, "tool"), []byte("#!/bin/sh\n"), 0o755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := &goInstall{t: t, dir: dir, list: &goList{latest: map[string]listing{
+		"example.com/tool": {Path: "example.com/tool", Version: "v1.1.0"},
+	}}}
+	results, err := New(Options{Dir: dir, Runner: g}).Upgrade(context.Background())
+	if err != nil {
+		t.Fatalf("Upgrade: %v", err)
+	}
+	if len(results) != 1 || results[0].Action != ActionUpgrade || results[0].File != filepath.Join(dir, "tool-old") {
+		t.Fatalf("got %+v, want tool-old upgraded", results)
+	}
+	want := map[string]string{"tool-old": "v1.1.0", "tool": "not a Go program"}
+	if got := versions(t, dir); !maps.Equal(got, want) {
+		t.Errorf("programs after upgrade %v, want %v", got, want)
+	}
+}
+
+func TestToolchainOnly(t *testing.T) {
+	fakePrograms(t)
+	dir := t.TempDir()
+	const pseudo = "v0.0.0-20240102150405-abcdef123456"
+	program(t, filepath.Join(dir, "tool"), prog("example.com/tool", "example.com/tool", "v1.0.0"))
+	program(t, filepath.Join(dir, "head"), prog("example.com/head", "example.com/head", pseudo))
+	program(t, filepath.Join(dir, "same"), &buildinfo.BuildInfo{GoVersion: "go1.23.0", Path: "example.com/same", Main: debug.Module{Path: "example.com/same", Version: "v1.0.0"}})
+	// Nothing is looked up.
+	g := &goInstall{t: t, dir: dir, goVersion: "go1.23.0", list: &goList{}}
+	results, err := New(Options{Dir: dir, Runner: g, ToolchainOnly: true}).Upgrade(context.Background())
+	if err != nil {
+		t.Fatalf("Upgrade: %v", err)
+	}
+	got := map[string]string{}
+	for _, r := range results {
+		got[r.Path] = r.Action + " " + r.Latest + " " + r.GoLatest
+	}
+	want := map[string]string{
+		"example.com/head": "upgrade " + pseudo + " go1.23.0",
+		"example.com/same": "latest v1.0.0 ",
+		"example.com/tool": "upgrade v1.0.0 go1.23.0",
+	}
+	if !maps.Equal(got, want) {
+		t.Errorf("results %q, want %q", got, want)
+	}
+	// The install target uses the current version.
+	installed := g.installed()
+	slices.Sort(installed)
+	if want := []string{"example.com/head@" + pseudo, "example.com/tool@v1.0.0"}; !slices.Equal(installed, want) {
+		t.Errorf("installed %q, want %q", installed, want)
+	}
+}
+
+func TestUpgradeNewerThanLatest(t *testing.T) {
+	fakePrograms(t)
+	for _, tt := range []struct {
+		name    string
+		current string
+		opts    Options
+		want    string
+	}{
+		{"release", "v1.3.0", Options{}, "skip v1.3.0 newer than latest v1.2.0"},
+		{"prerelease", "v1.3.0-rc.1", Options{}, "skip v1.3.0-rc.1 newer than latest v1.2.0"},
+		{"forced", "v1.3.0", Options{Force: true}, "reinstall v1.3.0 "},
+		{"downgrade", "v1.3.0", Options{AllowDowngrade: true}, "upgrade v1.2.0 "},
+	} {
+		dir := t.TempDir()
+		program(t, filepath.Join(dir, "tool"), prog("example.com/tool", "example.com/tool", tt.current))
+		g := &goInstall{t: t, dir: dir, list: &goList{latest: map[string]listing{
+			"example.com/tool": {Path: "example.com/tool", Version: "v1.2.0"},
+		}}}
+		tt.opts.Dir, tt.opts.Runner = dir, g
+		results, err := New(tt.opts).Upgrade(context.Background())
+		if err != nil {
+			t.Fatalf("%s: Upgrade: %v", tt.name, err)
+		}
+		if len(results) != 1 {
+			t.Fatalf("%s: got %d results, want 1", tt.name, len(results))
+		}
+		r := results[0]
+		if got := r.Action + " " + r.Latest + " " + r.Skipped; got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestUpgradePrograms(t *testing.T) {
+	fakePrograms(t)
+	dir := t.TempDir()
+	program(t, filepath.Join(dir, "tool"), prog("example.com/tool", "example.com/tool", "v1.0.0"))
+	program(t, filepath.Join(dir, "other"), prog("example.com/other", "example.com/other", "v1.0.0"))
+	g := &goInstall{t: t, dir: dir, list: &goList{latest: map[string]listing{
+		"example.com/tool": {Path: "example.com/tool", Version: "v1.1.0"},
+	}}}
+	results, err := New(Options{Dir: dir, Runner: g, Programs: []string{"tool"}}).Upgrade(context.Background())
+	if err != nil {
+		t.Fatalf("Upgrade: %v", err)
+	}
+	if len(results) != 1 || results[0].Path != "example.com/tool" || results[0].Action != ActionUpgrade {
+		t.Errorf("got %+v, want tool upgraded alone", results)
+	}
+	_, err = New(Options{Dir: dir, Runner: g, Programs: []string{"tool", "gone"}}).Upgrade(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "no program gone in") {
+		t.Errorf("got %v, want no program gone", err)
+	}
+}
+
+func TestDowngradeProtection(t *testing.T) {
+	fakePrograms(t)
+	for _, tt := range []struct {
+		name, latest string
+		allow        bool
+		action       string
+		installed    []string
+		warned       bool
+	}{
+		{"equal", "v1.2.0", false, ActionLatest, nil, false},
+		{"newer", "v1.3.0", false, ActionUpgrade, []string{"example.com/tool@v1.3.0"}, false},
+		{"older", "v1.1.0", false, ActionSkip, nil, true},
+		{"older allowed", "v1.1.0", true, ActionUpgrade, []string{"example.com/tool@v1.1.0"}, false},
+	} {
+		dir := t.TempDir()
+		program(t, filepath.Join(dir, "tool"), prog("example.com/tool", "example.com/tool", "v1.2.0"))
+		g := &goInstall{t: t, dir: dir, list: &goList{latest: map[string]listing{
+			"example.com/tool": {Path: "example.com/tool", Version: tt.latest},
+		}}}
+		var log strings.Builder
+		results, err := New(Options{
+			Dir:            dir,
+			Runner:         g,
+			AllowDowngrade: tt.allow,
+			Log:            slog.New(slog.NewTextHandler(&log, nil)),
+		}).Upgrade(context.Background())
+		if err != nil {
+			t.Fatalf("%s: Upgrade: %v", tt.name, err)
+		}
+		if len(results) != 1 || results[0].Action != tt.action {
+			t.Errorf("%s: got %+v, want %s", tt.name, results, tt.action)
+		}
+		if got := g.installed(); !slices.Equal(got, tt.installed) {
+			t.Errorf("%s: installed %q, want %q", tt.name, got, tt.installed)
+		}
+		if warned := strings.Contains(log.String(), "latest is older than installed"); warned != tt.warned {
+			t.Errorf("%s: warned %t, want %t:\n%s", tt.name, warned, tt.warned, log.String())
+		}
+	}
+}
+
+func TestForce(t *testing.T) {
+	fakePrograms(t)
+	for _, tt := range []struct {
+		name      string
+		current   string
+		opts      Options
+		action    string
+		installed []string
+	}{
+		{"latest", "v1.0.0", Options{}, ActionLatest, nil},
+		{"forced", "v1.0.0", Options{Force: true}, ActionReinstall, []string{"example.com/tool@v1.0.0"}},
+		// Pinned to a build of its own.
+		{"specific", "v1.0.0+dirty", Options{Force: true}, ActionSkip, nil},
+		{"force all", "v1.0.0+dirty", Options{ForceAll: true, Force: true}, ActionUpgrade, []string{"example.com/tool@v1.0.0"}},
+	} {
+		dir := t.TempDir()
+		program(t, filepath.Join(dir, "tool"), prog("example.com/tool", "example.com/tool", tt.current))
+		g := &goInstall{t: t, dir: dir, list: &goList{latest: map[string]listing{
+			"example.com/tool": {Path: "example.com/tool", Version: "v1.0.0"},
+		}}}
+		tt.opts.Dir, tt.opts.Runner = dir, g
+		results, err := New(tt.opts).Upgrade(context.Background())
+		if err != nil {
+			t.Fatalf("%s: Upgrade: %v", tt.name, err)
+		}
+		if len(results) != 1 || results[0].Action != tt.action {
+			t.Errorf("%s: got %+v, want %s", tt.name, results, tt.action)
+		}
+		if got := g.installed(); !slices.Equal(got, tt.installed) {
+			t.Errorf("%s: installed %q, want %q", tt.name, got, tt.installed)
+		}
+	}
+}
+
+func TestDuration(t *testing.T) {
+	fakePrograms(t)
+	dir := t.TempDir()
+	program(t, filepath.Join(dir, "tool"), prog("example.com/tool", "example.com/tool", "v1.0.0"))
+	program(t, filepath.Join(dir, "tool-copy"), prog("example.com/tool", "example.com/tool", "v1.0.0"))
+	g := &goInstall{t: t, dir: dir, delay: 20 * time.Millisecond, list: &goList{latest: map[string]listing{
+		"example.com/tool": {Path: "example.com/tool", Version: "v1.1.0"},
+	}}}
+	results, err := New(Options{Dir: dir, Runner: g}).Upgrade(context.Background())
+	if err != nil {
+		t.Fatalf("Upgrade: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("got %d results, want 2", len(results))
+	}
+	// The copy took as long as the install it is of.
+	for _, r := range results {
+		if r.Action != ActionUpgrade || r.Duration < g.delay {
+			t.Errorf("%s: %s in %s, want an upgrade taking at least %s", r.File, r.Action, r.Duration, g.delay)
+		}
+	}
+	if len(g.installs) != 1 {
+		t.Errorf("installed %d times, want once", len(g.installs))
+	}
+	var got struct {
+		DurationMS int64 `json:"duration_ms"`
+	}
+	b, err := json.Marshal(results[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if json.Unmarshal(b, &got); got.DurationMS < g.delay.Milliseconds() {
+		t.Errorf("duration_ms %d in %s, want at least %d", got.DurationMS, b, g.delay.Milliseconds())
+	}
+}
+
+func TestOffline(t *testing.T) {
+	fakePrograms(t)
+	modcache := t.TempDir()
+	proxy := fileURL(filepath.Join(modcache, "cache", "download"))
+	latest := map[string]listing{"example.com/tool": {Path: "example.com/tool", Version: "v1.1.0"}}
+	for _, tt := range []struct {
+		name    string
+		opts    Options
+		list    *goList
+		install string
+		want    string
+	}{
+		{
+			"proxy off", Options{Env: []string{"GOPROXY=off"}},
+			&goList{stderr: "go: example.com/tool@latest: module lookup disabled by GOPROXY=off\n"}, "",
+			"go list (exit status 1):\ngo: example.com/tool@latest: module lookup disabled by GOPROXY=off\n",
+		},
+		{
+			"not cached", Options{Offline: true},
+			&goList{latest: map[string]listing{"example.com/tool": {Path: "example.com/tool", Error: &listingError{"example.com/tool@latest: module lookup disabled by GOPROXY=off"}}}}, "",
+			"go list: not in the module cache: example.com/tool@latest: module lookup disabled by GOPROXY=off",
+		},
+		{
+			"latest not cached", Options{Offline: true}, &goList{latest: latest},
+			"go: example.com/tool@v1.1.0: reading " + proxy + "/example.com/tool/@v/v1.1.0.info: no such file or directory\n",
+			"go install: example.com/tool@v1.1.0 not in the module cache (exit status 1):\n",
+		},
+	} {
+		dir := t.TempDir()
+		program(t, filepath.Join(dir, "tool"), prog("example.com/tool", "example.com/tool", "v1.0.0"))
+		g := &goInstall{t: t, dir: dir, modcache: modcache, list: tt.list, stderr: tt.install}
+		tt.opts.Dir, tt.opts.Runner = dir, g
+		results, err := New(tt.opts).Upgrade(context.Background())
+		if err != nil {
+			t.Fatalf("%s: Upgrade: %v", tt.name, err)
+		}
+		if len(results) != 1 || results[0].Action != ActionError || !strings.HasPrefix(results[0].Err.Error(), tt.want) {
+			t.Errorf("%s: got %+v, want an error %q", tt.name, results, tt.want)
+		}
+		if len(tt.list.envs) == 0 {
+			t.Errorf("%s: ran no go list", tt.name)
+		}
+		// Never asking a proxy but the module cache when offline, the
+		// last of the env winning.
+		for _, env := range tt.list.envs {
+			var got string
+			for _, kv := range env {
+				if v, ok := strings.CutPrefix(kv, "GOPROXY="); ok {
+					got = v
+				}
+			}
+			if tt.opts.Offline && got != proxy {
+				t.Errorf("%s: go list with GOPROXY=%s, want %s", tt.name, got, proxy)
+			}
+		}
+	}
+}
+
+func TestMaxAge(t *testing.T) {
+	fakePrograms(t)
+	dir := t.TempDir()
+	g := &goInstall{t: t, dir: dir, list: &goList{latest: map[string]listing{}}}
+	for name, age := range map[string]time.Duration{"new": 0, "recent": 2 * time.Hour, "old": 48 * time.Hour, "older": 30 * 24 * time.Hour} {
+		file := filepath.Join(dir, binaryName(name))
+		program(t, file, prog("example.com/"+name, "example.com/"+name, "v1.0.0"))
+		installed := time.Now().Add(-age)
+		if err := os.Chtimes(file, installed, installed); err != nil {
+			t.Fatal(err)
+		}
+		g.list.latest["example.com/"+name] = listing{Path: "example.com/" + name, Version: "v1.1.0"}
+	}
+	results, err := New(Options{Dir: dir, Runner: g, MaxAge: 24 * time.Hour}).Upgrade(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]string{}
+	for _, r := range results {
+		got[r.Path] = r.Action
+	}
+	want := map[string]string{"example.com/new": ActionSkip, "example.com/recent": ActionSkip, "example.com/old": ActionUpgrade, "example.com/older": ActionUpgrade}
+	if !maps.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	// Nor looked up, if still checked for retractions like all.
+	var listed []string
+	for _, run := range g.list.runs {
+		for _, mod := range run {
+			if strings.HasSuffix(mod, "@latest") {
+				listed = append(listed, mod)
+			}
+		}
+	}
+	slices.Sort(listed)
+	if want := []string{"example.com/old@latest", "example.com/older@latest"}; !slices.Equal(listed, want) {
+		t.Errorf("listed %q, want %q", listed, want)
+	}
+}
+
+func TestUpgradeMajor(t *testing.T) {
+	fakePrograms(t)
+	for _, tt := range []struct {
+		name, latest string
+		action       string
+		installed    []string
+	}{
+		{"within v2", "v2.1.0", ActionUpgrade, []string{"example.com/tool/v2/cmd/tool@v2.1.0"}},
+		{"latest", "v2.0.0", ActionLatest, nil},
+		// A proxy answering for another major version.
+		{"v1", "v1.5.0", ActionError, nil},
+		{"v3", "v3.0.0", ActionError, nil},
+	} {
+		dir := t.TempDir()
+		program(t, filepath.Join(dir, "tool"), prog("example.com/tool/v2/cmd/tool", "example.com/tool/v2", "v2.0.0"))
+		g := &goInstall{t: t, dir: dir, mods: map[string]string{"example.com/tool/v2/cmd/tool": "example.com/tool/v2"}, list: &goList{latest: map[string]listing{
+			"example.com/tool/v2": {Path: "example.com/tool/v2", Version: tt.latest},
+			"example.com/tool":    {Path: "example.com/tool", Version: "v1.6.0"},
+		}}}
+		results, err := New(Options{Dir: dir, Runner: g}).Upgrade(context.Background())
+		if err != nil {
+			t.Fatalf("%s: Upgrade: %v", tt.name, err)
+		}
+		if len(results) != 1 || results[0].Action != tt.action {
+			t.Errorf("%s: got %+v, want %s", tt.name, results, tt.action)
+		}
+		if got := g.installed(); !slices.Equal(got, tt.installed) {
+			t.Errorf("%s: installed %q, want %q", tt.name, got, tt.installed)
+		}
+		// Never asking for the latest of v1.
+		for _, run := range g.list.runs {
+			if slices.Contains(run, "example.com/tool@latest") {
+				t.Errorf("%s: listed example.com/tool@latest", tt.name)
+			}
+		}
+		if tt.action == ActionUpgrade {
+			if v := versions(t, dir)[binaryName("tool")]; v != tt.latest {
+				t.Errorf("%s: left %s, want %s", tt.name, v, tt.latest)
+			}
+		}
+	}
+}
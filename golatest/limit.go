@@ -0,0 +1,108 @@
+package golatest
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/semaphore"
+)
+
+// keyLimit caps how many go commands run for any one key at a time, e.g.
+// fetching from a host to stay clear of rate limits. A nil keyLimit has
+// no limit.
+type keyLimit struct {
+	n int64
+	// key of module or package paths, the path itself if nil.
+	key func(p string) string
+
+	mu   sync.Mutex
+	sems map[string]*semaphore.Weighted
+}
+
+// newHostLimit of n per host, or none if n is not positive.
+func newHostLimit(n int) *keyLimit {
+	if n <= 0 {
+		return nil
+	}
+	return &keyLimit{n: int64(n), key: host, sems: map[string]*semaphore.Weighted{}}
+}
+
+// newModuleLimit of n per module, or none if n is not positive.
+func newModuleLimit(n int) *keyLimit {
+	if n <= 0 {
+		return nil
+	}
+	return &keyLimit{n: int64(n), sems: map[string]*semaphore.Weighted{}}
+}
+
+// acquire a slot for the key of module or package path p,
+// returning a func to release it with.
+func (l *keyLimit) acquire(ctx context.Context, p string) (func(), error) {
+	if l == nil {
+		return func() {}, nil
+	}
+	k := p
+	if l.key != nil {
+		k = l.key(p)
+	}
+	l.mu.Lock()
+	sem, ok := l.sems[k]
+	if !ok {
+		sem = semaphore.NewWeighted(l.n)
+		l.sems[k] = sem
+	}
+	l.mu.Unlock()
+
+	err := sem.Acquire(ctx, 1)
+	if err != nil {
+		return nil, err
+	}
+	return func() { sem.Release(1) }, nil
+}
+
+// rateLimit spaces out go commands querying the proxy to qps a second on
+// average, in bursts of up to a second's worth, like a token bucket.
+// A nil rateLimit has no limit.
+type rateLimit struct {
+	interval time.Duration
+
+	mu sync.Mutex
+	// next is when the next token is due, at most a second's worth ago.
+	next time.Time
+}
+
+// newRateLimit of qps queries a second, or none if qps is not positive.
+func newRateLimit(qps float64) *rateLimit {
+	if qps <= 0 {
+		return nil
+	}
+	return &rateLimit{interval: time.Duration(float64(time.Second) / qps)}
+}
+
+// wait for a token, or until ctx is done.
+func (l *rateLimit) wait(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	now := time.Now()
+	if burst := now.Add(-max(time.Second-l.interval, 0)); l.next.Before(burst) {
+		l.next = burst
+	}
+	at := l.next
+	l.next = l.next.Add(l.interval)
+	l.mu.Unlock()
+	if d := time.Until(at); d > 0 {
+		return sleep(ctx, d)
+	}
+	return nil
+}
+
+// host of module or package path p, e.g. golang.org for golang.org/x/tools.
+// Not necessarily where it's fetched from, but close enough.
+func host(p string) string {
+	h, _, _ := strings.Cut(p, "/")
+	return h
+}
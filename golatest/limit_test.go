@@ -0,0 +1,122 @@
+package golatest
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// acquired reports whether l.acquire for p gets a slot right away,
+// releasing it at the end of the test.
+func acquired(t *testing.T, l *keyLimit, p string) bool {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	release, err := l.acquire(ctx, p)
+	if err != nil {
+		return false
+	}
+	t.Cleanup(release)
+	return true
+}
+
+func TestHostLimit(t *testing.T) {
+	l := newHostLimit(2)
+	for i, p := range []string{"golang.org/x/tools", "golang.org/x/vuln"} {
+		if !acquired(t, l, p) {
+			t.Fatalf("acquire %d of 2 for golang.org blocked", i+1)
+		}
+	}
+	if acquired(t, l, "golang.org/x/mod") {
+		t.Errorf("acquire 3 of 2 for golang.org did not block")
+	}
+	if !acquired(t, l, "github.com/foo/bar") {
+		t.Errorf("acquire for github.com blocked by golang.org")
+	}
+}
+
+func TestModuleLimit(t *testing.T) {
+	l := newModuleLimit(1)
+	if !acquired(t, l, "golang.org/x/tools") {
+		t.Fatal("acquire 1 of 1 blocked")
+	}
+	if acquired(t, l, "golang.org/x/tools") {
+		t.Errorf("acquire 2 of 1 for the same module did not block")
+	}
+	if !acquired(t, l, "golang.org/x/tools/gopls") {
+		t.Errorf("acquire for another module of the same host blocked")
+	}
+}
+
+func TestKeyLimitRelease(t *testing.T) {
+	l := newModuleLimit(1)
+	release, err := l.acquire(context.Background(), "example.com/tool")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(chan error)
+	go func() {
+		release, err := l.acquire(context.Background(), "example.com/tool")
+		if err == nil {
+			release()
+		}
+		got <- err
+	}()
+	select {
+	case <-got:
+		t.Fatal("acquire did not wait for the release")
+	case <-time.After(10 * time.Millisecond):
+	}
+	release()
+	if err := <-got; err != nil {
+		t.Errorf("acquire after release: %v", err)
+	}
+}
+
+func TestNoKeyLimit(t *testing.T) {
+	for _, l := range []*keyLimit{newHostLimit(0), newModuleLimit(-1)} {
+		for i := 0; i < 100; i++ {
+			if !acquired(t, l, "example.com/tool") {
+				t.Fatalf("acquire %d without a limit blocked", i+1)
+			}
+		}
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	// 10 a second, in bursts of up to 10.
+	l := newRateLimit(10)
+	start := time.Now()
+	for i := 0; i < 10; i++ {
+		if err := l.wait(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if d := time.Since(start); d > 50*time.Millisecond {
+		t.Errorf("burst of 10 took %s, want no wait", d)
+	}
+	start = time.Now()
+	if err := l.wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Since(start); d < 50*time.Millisecond {
+		t.Errorf("11th of a burst of 10 took %s, want a tenth of a second", d)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := l.wait(ctx); err == nil {
+		t.Errorf("wait past ctx done: nil error")
+	}
+}
+
+func TestNoRateLimit(t *testing.T) {
+	for _, l := range []*rateLimit{newRateLimit(0), newRateLimit(-1)} {
+		if l != nil {
+			t.Fatalf("got limit %+v, want none", l)
+		}
+		if err := l.wait(context.Background()); err != nil {
+			t.Errorf("wait without a limit: %v", err)
+		}
+	}
+}
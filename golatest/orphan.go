@@ -0,0 +1,39 @@
+package golatest
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+)
+
+// isOrphaned reports whether go install output says the package is gone
+// from the module, as opposed to failing to build or download.
+//
+//	go: example.com/foo/cmd/bar@v1.2.0: module example.com/foo@v1.2.0 found,
+//	but does not contain package example.com/foo/cmd/bar
+func isOrphaned(out []byte) bool {
+	return bytes.Contains(out, []byte("but does not contain package"))
+}
+
+// orphaned handles a program whose package no longer exists at version of
+// its module, removing it if allowed to or that is confirmed.
+func orphaned(opts Options, res *Result, version string) {
+	res.Err = fmt.Errorf("package is no longer in %s@%s", res.Module, version)
+	remove := opts.RemoveOrphaned
+	if !remove && opts.Confirm != nil {
+		remove = opts.Confirm(fmt.Sprintf("remove %s, %s", res.File, res.Err))
+	}
+	if !remove {
+		res.Action = ActionOrphaned
+		res.Err = fmt.Errorf("%w, remove with: rm %s", res.Err, res.File)
+		return
+	}
+
+	err := os.Remove(res.File)
+	if err != nil {
+		res.Action = ActionError
+		res.Err = fmt.Errorf("remove orphaned: %w", err)
+		return
+	}
+	res.Action = ActionRemoved
+}
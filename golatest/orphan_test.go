@@ -0,0 +1,75 @@
+package golatest
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestIsOrphaned(t *testing.T) {
+	for _, tt := range []struct {
+		out  string
+		want bool
+	}{
+		{"go: example.com/foo/cmd/bar@v1.2.0: module example.com/foo@v1.2.0 found, but does not contain package example.com/foo/cmd/bar", true},
+		{"go: example.com/foo/cmd/bar@v1.2.0: reading https://proxy.golang.org/example.com/foo/@v/v1.2.0.zip: 404 Not Found", false},
+		{"# example.com/foo/cmd/bar\ncmd/bar/main.go:3:2: undefined: foo", false},
+		{"", false},
+	} {
+		if got := isOrphaned([]byte(tt.out)); got != tt.want {
+			t.Errorf("isOrphaned(%q) = %t, want %t", tt.out, got, tt.want)
+		}
+	}
+}
+
+func TestOrphaned(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		remove  bool
+		confirm func(string) bool
+		want    string
+		removed bool
+	}{
+		{name: "kept", want: ActionOrphaned},
+		{name: "remove orphaned", remove: true, want: ActionRemoved, removed: true},
+		{name: "confirmed", confirm: func(string) bool { return true }, want: ActionRemoved, removed: true},
+		{name: "declined", confirm: func(string) bool { return false }, want: ActionOrphaned},
+		{name: "remove orphaned without asking", remove: true, confirm: func(q string) bool {
+			t.Errorf("asked %q with RemoveOrphaned", q)
+			return false
+		}, want: ActionRemoved, removed: true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), "bar")
+			if err := os.WriteFile(file, []byte("bar"), 0o755); err != nil {
+				t.Fatal(err)
+			}
+			res := &Result{File: file, Path: "example.com/foo/cmd/bar", Module: "example.com/foo"}
+			orphaned(Options{RemoveOrphaned: tt.remove, Confirm: tt.confirm}, res, "v1.2.0")
+			if res.Action != tt.want {
+				t.Errorf("action %s, want %s", res.Action, tt.want)
+			}
+			if !strings.Contains(res.Err.Error(), "package is no longer in example.com/foo@v1.2.0") {
+				t.Errorf("err %v, want the package no longer in the module", res.Err)
+			}
+			if !tt.removed && !strings.Contains(res.Err.Error(), "remove with: rm "+file) {
+				t.Errorf("err %v, want how to remove it", res.Err)
+			}
+			_, err := os.Stat(file)
+			if removed := errors.Is(err, fs.ErrNotExist); removed != tt.removed {
+				t.Errorf("removed %t, want %t", removed, tt.removed)
+			}
+		})
+	}
+}
+
+func TestOrphanedRemoveFails(t *testing.T) {
+	res := &Result{File: filepath.Join(t.TempDir(), "gone"), Path: "example.com/foo/cmd/bar", Module: "example.com/foo"}
+	orphaned(Options{RemoveOrphaned: true}, res, "v1.2.0")
+	if res.Action != ActionError || !strings.Contains(res.Err.Error(), "remove orphaned") {
+		t.Errorf("got %s %v, want an error removing it", res.Action, res.Err)
+	}
+}
@@ -0,0 +1,48 @@
+package golatest
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// WritePlan of the upgrades planned in results to file name, for apply to
+// install later. It is a manifest with every version pinned.
+func WritePlan(name string, results []Result) error {
+	m := Manifest{Tools: []Tool{}}
+	for _, r := range results {
+		if r.Action != ActionPlanned {
+			continue
+		}
+		m.Tools = append(m.Tools, Tool{Path: r.Path, Module: r.Module, Version: r.Latest})
+	}
+	buf, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(name, append(buf, '\n'), 0o644)
+}
+
+// ReadPlan written by WritePlan.
+func ReadPlan(name string) (*Manifest, error) {
+	m, err := ReadManifest(name)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range m.Tools {
+		if t.Version == "" {
+			return nil, fmt.Errorf("plan %s: %s has no version", name, t.Path)
+		}
+	}
+	return m, nil
+}
+
+// pinned tool of package pkg in m, if any.
+func (m *Manifest) pinned(pkg string) (Tool, bool) {
+	for _, t := range m.Tools {
+		if t.Path == pkg {
+			return t, true
+		}
+	}
+	return Tool{}, false
+}
@@ -0,0 +1,119 @@
+package golatest
+
+import (
+	"context"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestPlanRoundTrip(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "plan.json")
+	err := WritePlan(file, []Result{
+		{Path: "golang.org/x/tools/gopls", Module: "golang.org/x/tools/gopls", Current: "v0.15.0", Latest: "v0.15.1", Action: ActionPlanned},
+		{Path: "example.com/tool", Module: "example.com/tool", Current: "v1.0.0", Action: ActionLatest},
+		{Path: "example.com/broken", Module: "example.com/broken", Current: "v1.0.0", Action: ActionError},
+		{Path: "example.com/cmd/run", Module: "example.com/cmd", Current: "v1.0.0", Latest: "v1.1.0", Action: ActionPlanned},
+	})
+	if err != nil {
+		t.Fatalf("WritePlan: %v", err)
+	}
+	m, err := ReadPlan(file)
+	if err != nil {
+		t.Fatalf("ReadPlan: %v", err)
+	}
+	want := []Tool{
+		{Path: "golang.org/x/tools/gopls", Module: "golang.org/x/tools/gopls", Version: "v0.15.1"},
+		{Path: "example.com/cmd/run", Module: "example.com/cmd", Version: "v1.1.0"},
+	}
+	if !slices.Equal(m.Tools, want) {
+		t.Errorf("read plan %+v, want %+v", m.Tools, want)
+	}
+	if tool, ok := m.pinned("example.com/cmd/run"); !ok || tool.Version != "v1.1.0" {
+		t.Errorf("pinned example.com/cmd/run = %+v, %t, want v1.1.0", tool, ok)
+	}
+	if _, ok := m.pinned("example.com/tool"); ok {
+		t.Errorf("pinned example.com/tool, which was already latest")
+	}
+}
+
+func TestPlanEmpty(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "plan.json")
+	if err := WritePlan(file, nil); err != nil {
+		t.Fatalf("WritePlan: %v", err)
+	}
+	buf, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// An empty list, not null, for apply to do nothing with.
+	if want := "{\n  \"tools\": []\n}\n"; string(buf) != want {
+		t.Errorf("wrote %q, want %q", buf, want)
+	}
+	m, err := ReadPlan(file)
+	if err != nil {
+		t.Fatalf("ReadPlan: %v", err)
+	}
+	if len(m.Tools) != 0 {
+		t.Errorf("read plan %+v, want no tools", m.Tools)
+	}
+}
+
+func TestReadPlanInvalid(t *testing.T) {
+	for _, tt := range []struct {
+		name, plan, want string
+	}{
+		{"missing version", `{"tools": [{"path": "example.com/tool", "version": "v1.0.0"}, {"path": "example.com/other"}]}`, "example.com/other has no version"},
+		{"missing path", `{"tools": [{"version": "v1.0.0"}]}`, "tool 0 has no path"},
+		{"not json", `tools: [example.com/tool]`, "invalid character"},
+	} {
+		file := filepath.Join(t.TempDir(), "plan.json")
+		if err := os.WriteFile(file, []byte(tt.plan), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		_, err := ReadPlan(file)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: ReadPlan error %v, want %q", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestApplyPlan(t *testing.T) {
+	fakePrograms(t)
+	dir := t.TempDir()
+	program(t, filepath.Join(dir, "tool"), prog("example.com/tool", "example.com/tool", "v1.0.0"))
+	program(t, filepath.Join(dir, "run"), prog("example.com/cmd/run", "example.com/cmd", "v1.0.0"))
+	program(t, filepath.Join(dir, "other"), prog("example.com/other", "example.com/other", "v1.0.0"))
+	// Latest has moved on since the plan.
+	g := &goInstall{t: t, dir: dir, mods: map[string]string{"example.com/cmd/run": "example.com/cmd"}, list: &goList{latest: map[string]listing{
+		"example.com/tool":  {Path: "example.com/tool", Version: "v1.3.0"},
+		"example.com/cmd":   {Path: "example.com/cmd", Version: "v1.3.0"},
+		"example.com/other": {Path: "example.com/other", Version: "v1.3.0"},
+		"example.com/new":   {Path: "example.com/new", Version: "v1.3.0"},
+	}}}
+	plan := &Manifest{Tools: []Tool{
+		{Path: "example.com/tool", Module: "example.com/tool", Version: "v1.1.0"},
+		{Path: "example.com/cmd/run", Module: "example.com/cmd", Version: "v1.2.0"},
+		{Path: "example.com/new", Module: "example.com/new", Version: "v0.1.0"},
+	}}
+	results, err := New(Options{Dir: dir, Runner: g, Plan: plan, Workers: 1}).Upgrade(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := g.installed()
+	slices.Sort(got)
+	if want := []string{"example.com/cmd/run@v1.2.0", "example.com/new@v0.1.0", "example.com/tool@v1.1.0"}; !slices.Equal(got, want) {
+		t.Errorf("installed %q, want %q", got, want)
+	}
+	if want := map[string]string{"tool": "v1.1.0", "run": "v1.2.0", "other": "v1.0.0", "new": "v0.1.0"}; !maps.Equal(versions(t, dir), want) {
+		t.Errorf("left %v, want %v", versions(t, dir), want)
+	}
+	for _, r := range results {
+		if r.Action == ActionError {
+			t.Errorf("%s: %v", r.Path, r.Err)
+		}
+	}
+}
@@ -0,0 +1,8 @@
+//go:build !unix
+
+package golatest
+
+import "os/exec"
+
+// killGroup is a no-op, only the process itself is killed on cancellation.
+func killGroup(cmd *exec.Cmd) {}
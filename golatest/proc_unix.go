@@ -0,0 +1,18 @@
+//go:build unix
+
+package golatest
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// killGroup makes cmd the leader of a new process group, killing all of
+// the group on cancellation.
+func killGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		// Negative pid signals the whole group.
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+}
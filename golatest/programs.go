@@ -0,0 +1,163 @@
+package golatest
+
+import (
+	"bytes"
+	"context"
+	"debug/buildinfo"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"sort"
+	"strings"
+
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
+)
+
+// GOBIN where go install puts programs, found from the environment.
+func GOBIN() string {
+	gobin := os.Getenv("GOBIN")
+	if gobin != "" {
+		return gobin
+	}
+	home := os.Getenv("HOME")
+	if home != "" {
+		return filepath.Join(home, "go", "bin")
+	}
+	return ""
+}
+
+// readBuildInfo of a Go program file, as tests fake it.
+var readBuildInfo = buildinfo.ReadFile
+
+func goversion(ctx context.Context, run *runner) (string, error) {
+	out, err := run.goCombined(ctx, nil, "env", "GOVERSION")
+	if err != nil {
+		return "", fmt.Errorf("go env (%w):\n%s", err, out)
+	}
+
+	return string(bytes.TrimSpace(out)), nil
+}
+
+// List the programs in dir, GOBIN if empty, as they were built, sorted by
+// package path. Nothing is looked up, so there is no Action or Latest.
+// Executables that are not Go programs are left out.
+func List(dir string) ([]Result, error) {
+	if dir == "" {
+		dir = GOBIN()
+	}
+	if dir == "" {
+		return nil, errors.New("GOBIN not found")
+	}
+	progs, err := listPrograms(dir)
+	if err != nil {
+		return nil, err
+	}
+	var results []Result
+	for _, f := range progs {
+		info, err := readBuildInfo(f)
+		if err != nil {
+			continue
+		}
+		r := Result{File: f}
+		r.built(info)
+		results = append(results, r)
+	}
+	sort.SliceStable(results, func(i, j int) bool { return results[i].Path < results[j].Path })
+	return results, nil
+}
+
+// named of progs in dir, those files with names, with or without an .exe
+// on Windows, failing if any of names is not one of them.
+func named(dir string, progs, names []string) ([]string, error) {
+	found := map[string]bool{}
+	var named []string
+	for _, p := range progs {
+		name := filepath.Base(p)
+		if runtime.GOOS == "windows" && !slices.Contains(names, name) {
+			name = strings.TrimSuffix(name, ".exe")
+		}
+		if slices.Contains(names, name) {
+			named = append(named, p)
+			found[name] = true
+		}
+	}
+	for _, name := range names {
+		if !found[name] {
+			return nil, fmt.Errorf("no program %s in %s", name, dir)
+		}
+	}
+	return named, nil
+}
+
+func listPrograms(dir string) ([]string, error) {
+	fi, err := os.Stat(dir)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return nil, fmt.Errorf("GOBIN directory does not exist: %s", dir)
+	case err != nil:
+		return nil, err
+	case !fi.IsDir():
+		return nil, fmt.Errorf("GOBIN is not a directory: %s", dir)
+	}
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var programs []string
+	for _, de := range files {
+		fi, err := de.Info()
+		if err != nil {
+			return nil, fmt.Errorf("info %q: %w", de.Name(), err)
+		}
+		if isExecutable(fi) {
+			programs = append(programs, filepath.Join(dir, fi.Name()))
+		}
+	}
+	return programs, nil
+}
+
+func isExecutable(fi fs.FileInfo) bool {
+	return fi.Mode().Perm()&0111 != 0
+}
+
+// isSpecific revision installed from local repo or a modified checkout.
+// In other words not some generally available package installed with @latest.
+// Tagged prereleases are generally available, @latest picks them for modules
+// without releases. Pseudo-versions of a specific SHA are looked up too, to
+// upgrade to a release tagged after them.
+func isSpecific(v string) bool {
+	// Local
+	if v == "(devel)" {
+		return true
+	}
+	if !semver.IsValid(v) {
+		return false
+	}
+	// Built from a modified checkout, e.g. v1.2.3+dirty.
+	// Unlike +incompatible, which only marks a v2+ module without a go.mod.
+	b := semver.Build(v)
+	return b != "" && b != "+incompatible"
+}
+
+// modulePath that info's program was built from.
+// Programs built outside of module mode don't record one, in which case
+// the package path is the best guess, up to any major version suffix.
+func modulePath(info *buildinfo.BuildInfo) string {
+	if info.Main.Path != "" {
+		return info.Main.Path
+	}
+	elems := strings.Split(info.Path, "/")
+	for i := len(elems); i > 1; i-- {
+		prefix := strings.Join(elems[:i], "/")
+		_, major, ok := module.SplitPathVersion(prefix)
+		if ok && major != "" {
+			return prefix
+		}
+	}
+	return info.Path
+}
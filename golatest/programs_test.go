@@ -0,0 +1,25 @@
+package golatest
+
+import "testing"
+
+func TestIsSpecific(t *testing.T) {
+	for _, tt := range []struct {
+		v    string
+		want bool
+	}{
+		{"(devel)", true},
+		{"v1.2.3", false},
+		{"v1.2.3-rc.1", false},
+		{"v0.0.0-20240102030405-abcdefabcdef", false},
+		{"v1.2.4-0.20240102030405-abcdefabcdef", false},
+		{"v2.0.0+incompatible", false},
+		{"v1.2.3+dirty", true},
+		{"v0.0.0-20240102030405-abcdefabcdef+dirty", true},
+		{"", false},
+		{"latest", false},
+	} {
+		if got := isSpecific(tt.v); got != tt.want {
+			t.Errorf("isSpecific(%q) = %t, want %t", tt.v, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,533 @@
+package golatest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"path"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
+	"golang.org/x/sync/errgroup"
+)
+
+// batchSize is the most modules looked up by a single go list.
+const batchSize = 64
+
+// batchBytes is the most bytes of module paths in a single go list, well
+// within the 32 KiB of a command line on Windows.
+const batchBytes = 16 << 10
+
+// resolver looks up the latest versions of modules, remembering what it
+// found. Lookups are batched into as few runs of go list as it can.
+type resolver struct {
+	// pre includes prereleases.
+	pre bool
+	// offline looks up modules in the module cache alone.
+	offline bool
+	// workers is how many batches of go list run at once, each for a
+	// host of its own unless it has more than batchSize modules.
+	workers int
+	run     *runner
+	hosts   *keyLimit
+	// throttle of lookups, backing off while the proxy turns them away.
+	throttle *throttle
+	// timeout of each go list, if positive.
+	timeout time.Duration
+	// rate of go commands querying the proxy, and queries how many ran.
+	rate    *rateLimit
+	queries atomic.Int64
+	// log of lookups tried again, if set.
+	log *slog.Logger
+	// cache of earlier lookups, if set.
+	cache *Cache
+
+	mu    sync.Mutex
+	known map[string]lookup
+}
+
+type lookup struct {
+	version string
+	// released is when version was, if the proxy says.
+	released time.Time
+	// retracted newer version passed over, and why it was retracted.
+	retracted, rationale string
+	// deprecated notice of the module as of version, if any.
+	deprecated string
+	err        error
+	// took the go list finding it, shared by all modules in its batch.
+	took time.Duration
+}
+
+func newResolver(pre, offline bool, workers int, run *runner, hosts *keyLimit, cache *Cache) *resolver {
+	return &resolver{pre: pre, offline: offline, workers: workers, run: run, hosts: hosts, throttle: newThrottle(workers), cache: cache, known: map[string]lookup{}}
+}
+
+// cacheKey of mod in the cache.
+func (r *resolver) cacheKey(mod string) string {
+	if r.pre {
+		return mod + "+pre"
+	}
+	return mod
+}
+
+// cached latest version of mod, remembered as known if found.
+// Called with mu held.
+func (r *resolver) cached(mod string) bool {
+	if r.cache == nil {
+		return false
+	}
+	l, ok := r.cache.get(r.cacheKey(mod))
+	if ok {
+		r.known[mod] = l
+	}
+	return ok
+}
+
+// prefetch the latest versions of mods, in batches of a single host each.
+// Failures are remembered for latestModule to return.
+func (r *resolver) prefetch(ctx context.Context, mods []string) {
+	var todo []string
+	seen := map[string]bool{}
+	r.mu.Lock()
+	for _, mod := range mods {
+		if _, ok := r.known[mod]; !ok && !seen[mod] && !r.cached(mod) {
+			seen[mod] = true
+			todo = append(todo, mod)
+		}
+	}
+	r.mu.Unlock()
+
+	var eg errgroup.Group
+	eg.SetLimit(r.workers)
+	for _, batch := range batches(todo) {
+		batch := batch
+		eg.Go(func() error {
+			if ctx.Err() == nil {
+				r.fetch(ctx, batch)
+			}
+			return nil
+		})
+	}
+	eg.Wait()
+}
+
+// compact sorted copy of mods, without duplicates.
+func compact(mods []string) []string {
+	mods = slices.Clone(mods)
+	slices.Sort(mods)
+	return slices.Compact(mods)
+}
+
+// batches of mods, each of at most batchSize modules and batchBytes of
+// paths, from a single host.
+func batches(mods []string) [][]string {
+	mods = slices.Clone(mods)
+	sort.SliceStable(mods, func(i, j int) bool {
+		return host(mods[i]) < host(mods[j])
+	})
+	var bs [][]string
+	for len(mods) > 0 {
+		n, size := 1, len(mods[0])
+		for n < min(len(mods), batchSize) && host(mods[n]) == host(mods[0]) && size+len(mods[n]) <= batchBytes {
+			size += len(mods[n])
+			n++
+		}
+		bs = append(bs, mods[:n])
+		mods = mods[n:]
+	}
+	return bs
+}
+
+// latest version of the module providing package pkg, as well as its path.
+// It is looked up as mod first and failing that, among the prefixes of pkg,
+// innermost first, like go install pkg@latest would. E.g. vanity paths that
+// no longer resolve, or commands split out into modules of their own,
+// but never the modules mod is of another major version of.
+// The lookup took is that of all modules tried.
+func (r *resolver) latest(ctx context.Context, mod, pkg string) (string, lookup) {
+	l := r.latestModule(ctx, mod)
+	if l.err == nil || ctx.Err() != nil {
+		return mod, l
+	}
+	_, major, _ := module.SplitPathVersion(mod)
+	var prefixes []string
+	for p := pkg; strings.Contains(p, "/"); p = path.Dir(p) {
+		// E.g. example.com/tool for example.com/tool/v2.
+		if p != mod && !(major != "" && strings.HasPrefix(mod, p+"/")) {
+			prefixes = append(prefixes, p)
+		}
+	}
+	r.prefetch(ctx, prefixes)
+	took := l.took
+	for _, p := range prefixes {
+		pl := r.latestModule(ctx, p)
+		took += pl.took
+		if pl.err == nil || ctx.Err() != nil {
+			pl.took = took
+			return p, pl
+		}
+	}
+	l.took = took
+	return "", l
+}
+
+// latestModule version of module mod, or error.
+// The version is always within the major version of the module path,
+// e.g. v2.x.y for example.com/foo/v2.
+func (r *resolver) latestModule(ctx context.Context, mod string) lookup {
+	r.mu.Lock()
+	l, ok := r.known[mod]
+	if !ok && r.cached(mod) {
+		l, ok = r.known[mod], true
+	}
+	r.mu.Unlock()
+	if ok {
+		return l
+	}
+
+	r.fetch(ctx, []string{mod})
+	r.mu.Lock()
+	l, ok = r.known[mod]
+	r.mu.Unlock()
+	if !ok {
+		// Only cancellation leaves nothing behind.
+		return lookup{err: ctx.Err()}
+	}
+	return l
+}
+
+// fetch the latest versions of mods, all from the same host,
+// remembering the outcome for each.
+func (r *resolver) fetch(ctx context.Context, mods []string) {
+	release, err := r.hosts.acquire(ctx, mods[0])
+	if err != nil {
+		return
+	}
+	defer release()
+
+	found := r.listRetrying(ctx, mods, r.pre)
+	if r.pre {
+		// Nothing tagged, @latest falls back on a pseudo-version.
+		var untagged []string
+		for mod, l := range found {
+			if l.err == nil && l.version == "" {
+				untagged = append(untagged, mod)
+			}
+		}
+		if len(untagged) > 0 {
+			for mod, l := range r.listRetrying(ctx, untagged, false) {
+				l.took += found[mod].took
+				found[mod] = l
+			}
+		}
+	}
+	// A retracted @latest, which go list only reports with -retracted,
+	// falls back on the newest version that is not.
+	var retracted []string
+	for mod, l := range found {
+		if l.err == nil && l.retracted != "" {
+			retracted = append(retracted, mod)
+		}
+	}
+	if len(retracted) > 0 {
+		for mod, l := range r.listRetrying(ctx, retracted, true) {
+			if l.err == nil && l.version == "" {
+				l.err = fmt.Errorf("go list: every version of %s is retracted", mod)
+			}
+			l.retracted, l.rationale = found[mod].retracted, found[mod].rationale
+			l.deprecated = found[mod].deprecated
+			l.took += found[mod].took
+			found[mod] = l
+		}
+	}
+	if ctx.Err() != nil {
+		// Lookups cut short are not failures to remember.
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for mod, l := range found {
+		r.known[mod] = l
+		if r.cache != nil && l.err == nil {
+			r.cache.put(r.cacheKey(mod), l)
+		}
+	}
+}
+
+// within the version prefix query, the latest of module mod at v, e.g.
+// v1.2.5 for v1.2.3 in v1.2, or v itself if there is none newer.
+func (r *resolver) within(ctx context.Context, mod, v, query string) lookup {
+	release, err := r.hosts.acquire(ctx, mod)
+	if err != nil {
+		return lookup{err: err}
+	}
+	defer release()
+	start := time.Now()
+	// A query of a version prefix is the latest matching it, retracted
+	// ones left out.
+	out, stderr, err := r.goRetrying(ctx, "list", "-m", "-json", mod+"@"+query)
+	l := lookup{took: time.Since(start)}
+	if err != nil {
+		l.err = fmt.Errorf("go list (%w):\n%s", err, stderr)
+		return l
+	}
+	var listing struct {
+		Version string
+		Time    string
+	}
+	l.err = json.Unmarshal(out, &listing)
+	if l.err != nil || semver.Compare(listing.Version, v) < 0 {
+		l.version = v
+		return l
+	}
+	l.version = listing.Version
+	l.released, _ = time.Parse(time.RFC3339, listing.Time)
+	return l
+}
+
+// between versions from and to of module mod, the releases after from up
+// to and including to, retracted ones left out.
+func (r *resolver) between(ctx context.Context, mod, from, to string) ([]string, error) {
+	release, err := r.hosts.acquire(ctx, mod)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	out, stderr, err := r.goRetrying(ctx, "list", "-m", "-json", "-versions", mod)
+	if err != nil {
+		return nil, fmt.Errorf("go list (%w):\n%s", err, stderr)
+	}
+	var listing struct {
+		Versions []string
+	}
+	err = json.Unmarshal(out, &listing)
+	if err != nil {
+		return nil, err
+	}
+	var vs []string
+	for _, v := range listing.Versions {
+		if semver.Compare(v, from) > 0 && semver.Compare(v, to) <= 0 {
+			vs = append(vs, v)
+		}
+	}
+	return vs, nil
+}
+
+// goCmd runs go with args to query the proxy, once rate allows it.
+func (r *resolver) goCmd(ctx context.Context, args ...string) (stdout, stderr []byte, err error) {
+	err = r.rate.wait(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	r.queries.Add(1)
+	return r.run.goCmd(ctx, nil, args...)
+}
+
+// list the latest versions of mods with a single go list.
+// With versions, the highest tagged version is listed, prereleases
+// included if pre, since @latest only picks a prerelease when there are
+// no releases. Retracted versions are not. It is empty when there are no
+// tags at all.
+func (r *resolver) list(ctx context.Context, mods []string, versions bool) map[string]lookup {
+	found := map[string]lookup{}
+	args := []string{"list", "-m", "-e", "-json"}
+	if versions {
+		args = append(args, "-versions")
+	} else {
+		// Lest a retracted @latest go by unnoticed, nor a deprecated
+		// module, which only -u tells.
+		args = append(args, "-retracted", "-u")
+	}
+	n := 0
+	for _, mod := range mods {
+		if _, _, ok := module.SplitPathVersion(mod); !ok {
+			found[mod] = lookup{err: fmt.Errorf("invalid module path %q", mod)}
+			continue
+		}
+		if !versions {
+			mod += "@latest"
+		}
+		args = append(args, mod)
+		n++
+	}
+	if n == 0 {
+		return found
+	}
+
+	start := time.Now()
+	out, stderr, err := r.goCmd(ctx, args...)
+	took := time.Since(start)
+	if ctx.Err() != nil {
+		return found
+	}
+	if err != nil {
+		// With -e, errors for single modules are part of the output.
+		// Find the one that failed the whole lot, if there are several.
+		if n == 1 {
+			for _, mod := range mods {
+				if _, ok := found[mod]; !ok {
+					found[mod] = lookup{err: fmt.Errorf("go list (%w):\n%s", err, stderr), took: took}
+				}
+			}
+			return found
+		}
+		for _, mod := range mods {
+			if _, ok := found[mod]; !ok {
+				for m, l := range r.list(ctx, []string{mod}, versions) {
+					found[m] = l
+				}
+			}
+		}
+		return found
+	}
+
+	// A stream of JSON objects, one per module.
+	dec := json.NewDecoder(bytes.NewReader(out))
+	for {
+		var listing struct {
+			Path     string
+			Version  string
+			Time     string
+			Versions []string
+			// Retracted is only listed with -retracted.
+			Retracted []string
+			// Deprecated is only listed with -u, and Update of a
+			// version but the latest, as older go commands list a
+			// module at the version it resolved to rather than @latest.
+			Deprecated string
+			Update     *struct {
+				Version string
+				Time    string
+			}
+			Error *struct {
+				Err string
+			}
+		}
+		err = dec.Decode(&listing)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			err = fmt.Errorf("json decode: %v", err)
+			break
+		}
+		_, major, _ := module.SplitPathVersion(listing.Path)
+		l := lookup{took: took}
+		switch {
+		case listing.Error != nil && r.offline:
+			// Nothing but the cache to miss.
+			l.err = fmt.Errorf("go list: not in the module cache: %s", listing.Error.Err)
+		case listing.Error != nil:
+			l.err = fmt.Errorf("go list: %s", listing.Error.Err)
+		case versions:
+			l.version = maxVersion(listing.Versions, major, r.pre)
+		case listing.Update != nil && semver.Compare(listing.Update.Version, listing.Version) > 0:
+			// Retracted or not, it is not the latest.
+			l.version, l.deprecated = listing.Update.Version, listing.Deprecated
+			l.err = module.CheckPathMajor(l.version, major)
+			l.released, _ = time.Parse(time.RFC3339, listing.Update.Time)
+		case len(listing.Retracted) > 0:
+			l.retracted, l.rationale = listing.Version, strings.Join(listing.Retracted, "; ")
+			l.deprecated = listing.Deprecated
+		default:
+			l.version, l.deprecated = listing.Version, listing.Deprecated
+			l.err = module.CheckPathMajor(listing.Version, major)
+			// Not all proxies tell, nor is it worth failing over.
+			l.released, _ = time.Parse(time.RFC3339, listing.Time)
+		}
+		found[listing.Path] = l
+	}
+	if err == io.EOF {
+		err = errors.New("go list: not listed")
+	}
+	for _, mod := range mods {
+		if _, ok := found[mod]; !ok {
+			found[mod] = lookup{err: err, took: took}
+		}
+	}
+	return found
+}
+
+// retractions of installed module versions, mod@version each, as why
+// each retracted one was by mod@version. Versions that cannot be looked up
+// are taken not to be retracted, resolving them will tell why.
+func (r *resolver) retractions(ctx context.Context, installed []string) map[string]string {
+	retracted := map[string]string{}
+	var mu sync.Mutex
+	var eg errgroup.Group
+	eg.SetLimit(r.workers)
+	for _, batch := range batches(compact(installed)) {
+		batch := batch
+		eg.Go(func() error {
+			if ctx.Err() == nil {
+				found := r.listRetracted(ctx, batch)
+				mu.Lock()
+				maps.Copy(retracted, found)
+				mu.Unlock()
+			}
+			return nil
+		})
+	}
+	eg.Wait()
+	return retracted
+}
+
+// listRetracted mods@version with a single go list, returning why those
+// retracted were.
+func (r *resolver) listRetracted(ctx context.Context, mods []string) map[string]string {
+	retracted := map[string]string{}
+	release, err := r.hosts.acquire(ctx, mods[0])
+	if err != nil {
+		return retracted
+	}
+	defer release()
+	out, _, _ := r.goCmd(ctx, append([]string{"list", "-m", "-e", "-json", "-retracted"}, mods...)...)
+	dec := json.NewDecoder(bytes.NewReader(out))
+	for {
+		var listing struct {
+			Path      string
+			Version   string
+			Retracted []string
+		}
+		if dec.Decode(&listing) != nil {
+			return retracted
+		}
+		if len(listing.Retracted) > 0 {
+			retracted[listing.Path+"@"+listing.Version] = strings.Join(listing.Retracted, "; ")
+		}
+	}
+}
+
+// maxVersion of vs by semver, within pathMajor. Unless pre, a release
+// is taken over any prerelease, like @latest does.
+func maxVersion(vs []string, pathMajor string, pre bool) string {
+	max := ""
+	for _, v := range vs {
+		if module.CheckPathMajor(v, pathMajor) != nil {
+			continue
+		}
+		if !pre && max != "" && (semver.Prerelease(v) == "") != (semver.Prerelease(max) == "") {
+			if semver.Prerelease(v) == "" {
+				max = v
+			}
+			continue
+		}
+		if max == "" || semver.Compare(v, max) > 0 {
+			max = v
+		}
+	}
+	return max
+}
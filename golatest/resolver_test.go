@@ -0,0 +1,324 @@
+package golatest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestBatches(t *testing.T) {
+	long := strings.Repeat("x", 4<<10)
+	var many, big []string
+	for i := 0; i < batchSize+1; i++ {
+		many = append(many, fmt.Sprintf("github.com/owner/repo%d", i))
+	}
+	for i := 0; i < 5; i++ {
+		big = append(big, fmt.Sprintf("github.com/owner/%s%d", long, i))
+	}
+	for _, tt := range []struct {
+		name string
+		mods []string
+		want []int
+	}{
+		{"none", nil, nil},
+		{"one host", []string{"golang.org/x/tools/gopls", "golang.org/x/vuln"}, []int{2}},
+		{"hosts", []string{"golang.org/x/tools/gopls", "github.com/owner/repo", "golang.org/x/vuln"}, []int{1, 2}},
+		{"too many", many, []int{batchSize, 1}},
+		// Some 4 KiB each, 3 of which fit within batchBytes.
+		{"too long", big, []int{3, 2}},
+	} {
+		bs := batches(tt.mods)
+		var got []int
+		seen := map[string]bool{}
+		for _, b := range bs {
+			got = append(got, len(b))
+			size := 0
+			for _, mod := range b {
+				size += len(mod)
+				seen[mod] = true
+				if host(mod) != host(b[0]) {
+					t.Errorf("%s: batch of %s has %s of another host", tt.name, host(b[0]), mod)
+				}
+			}
+			if size > batchBytes {
+				t.Errorf("%s: batch of %d bytes, want at most %d", tt.name, size, batchBytes)
+			}
+		}
+		if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("%s: batches of %v, want %v", tt.name, got, tt.want)
+		}
+		if len(seen) != len(tt.mods) {
+			t.Errorf("%s: batched %d of %d modules", tt.name, len(seen), len(tt.mods))
+		}
+	}
+}
+
+// listing of a module as go list -m -json prints it.
+type listing struct {
+	Path       string
+	Version    string   `json:",omitempty"`
+	Versions   []string `json:",omitempty"`
+	Retracted  []string `json:",omitempty"`
+	Deprecated string   `json:",omitempty"`
+	// Error of the module alone, that go list -e prints.
+	Error *listingError `json:",omitempty"`
+}
+
+type listingError struct {
+	Err string
+}
+
+// goList is a fakeRunner of go list -m, answering with the listings of
+// modules@latest by module, those of modules@version by both, or with
+// -versions those of modules, and recording the modules and env of each
+// run. With stderr, every run fails with it, as go does with no proxy.
+type goList struct {
+	latest, versions map[string]listing
+	stderr           string
+
+	mu   sync.Mutex
+	runs [][]string
+	envs [][]string
+}
+
+func (g *goList) Run(_ context.Context, env []string, args ...string) ([]byte, []byte, error) {
+	if len(args) < 2 || args[0] != "list" || args[1] != "-m" {
+		return nil, nil, fmt.Errorf("unexpected go %s", strings.Join(args, " "))
+	}
+	var mods []string
+	listings := g.latest
+	for _, arg := range args[2:] {
+		switch {
+		case arg == "-versions":
+			listings = g.versions
+		case !strings.HasPrefix(arg, "-"):
+			mods = append(mods, arg)
+		}
+	}
+	g.mu.Lock()
+	g.runs = append(g.runs, mods)
+	g.envs = append(g.envs, env)
+	g.mu.Unlock()
+	if g.stderr != "" {
+		return nil, []byte(g.stderr), fmt.Errorf("exit status 1")
+	}
+
+	var out []byte
+	for _, mod := range mods {
+		mod = strings.TrimSuffix(mod, "@latest")
+		l, ok := listings[mod]
+		if !ok {
+			return nil, []byte("unexpected " + mod), fmt.Errorf("exit status 1")
+		}
+		buf, _ := json.Marshal(l)
+		out = append(out, buf...)
+		out = append(out, '\n')
+	}
+	return out, nil, nil
+}
+
+func TestPrefetchBatches(t *testing.T) {
+	g := &goList{latest: map[string]listing{}}
+	var mods []string
+	for i := 0; i < 10; i++ {
+		// Some 4 KiB each, 3 of which fit within batchBytes.
+		mod := fmt.Sprintf("github.com/owner/%s%d", strings.Repeat("x", 4<<10), i)
+		mods = append(mods, mod)
+		g.latest[mod] = listing{Path: mod, Version: "v1.0.0"}
+	}
+	mods = append(mods, "golang.org/x/vuln")
+	g.latest["golang.org/x/vuln"] = listing{Path: "golang.org/x/vuln", Version: "v1.1.0"}
+
+	r := newResolver(false, false, 4, &runner{Runner: g}, nil, nil)
+	r.prefetch(context.Background(), mods)
+	if len(g.runs) != 5 {
+		t.Errorf("ran go list %d times, want 4 for github.com and 1 for golang.org", len(g.runs))
+	}
+	for _, run := range g.runs {
+		size := 0
+		for _, mod := range run {
+			size += len(mod)
+		}
+		if size > batchBytes+len(run)*len("@latest") {
+			t.Errorf("go list of %d bytes of modules, want at most %d", size, batchBytes)
+		}
+	}
+	for _, mod := range mods {
+		if l := r.latestModule(context.Background(), mod); l.err != nil || l.version == "" {
+			t.Errorf("latest of %s %+v, want it prefetched", mod, l)
+		}
+	}
+	if len(g.runs) != 5 {
+		t.Errorf("ran go list %d times, want none after prefetching", len(g.runs)-5)
+	}
+}
+
+func TestDeprecated(t *testing.T) {
+	g := &goList{
+		latest: map[string]listing{
+			"example.com/old":       {Path: "example.com/old", Version: "v1.2.0", Deprecated: "use example.com/new"},
+			"example.com/new":       {Path: "example.com/new", Version: "v1.0.0"},
+			"example.com/retracted": {Path: "example.com/retracted", Version: "v1.3.0", Retracted: []string{"broken"}, Deprecated: "use example.com/new"},
+		},
+		versions: map[string]listing{
+			"example.com/retracted": {Path: "example.com/retracted", Versions: []string{"v1.1.0", "v1.2.0"}},
+		},
+	}
+	cache, err := OpenCache(t.TempDir()+"/latest.json", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := newResolver(false, false, 4, &runner{Runner: g}, nil, cache)
+	for _, tt := range []struct {
+		mod, version, deprecated string
+	}{
+		{"example.com/old", "v1.2.0", "use example.com/new"},
+		{"example.com/new", "v1.0.0", ""},
+		// Deprecated as of the retracted latest, still.
+		{"example.com/retracted", "v1.2.0", "use example.com/new"},
+	} {
+		l := r.latestModule(context.Background(), tt.mod)
+		if l.err != nil || l.version != tt.version || l.deprecated != tt.deprecated {
+			t.Errorf("latest of %s = %s %q, %v, want %s %q", tt.mod, l.version, l.deprecated, l.err, tt.version, tt.deprecated)
+		}
+		// And so from the cache.
+		if c, ok := cache.get(tt.mod); !ok || c.deprecated != tt.deprecated {
+			t.Errorf("cached %s deprecated %q, %t, want %q", tt.mod, c.deprecated, ok, tt.deprecated)
+		}
+	}
+}
+
+func TestMaxVersion(t *testing.T) {
+	for _, tt := range []struct {
+		vs        []string
+		pathMajor string
+		pre       bool
+		want      string
+	}{
+		{nil, "", false, ""},
+		{[]string{"v1.0.0", "v1.2.0", "v1.10.0"}, "", false, "v1.10.0"},
+		{[]string{"v1.0.0", "v1.1.0-rc.1"}, "", false, "v1.0.0"},
+		{[]string{"v1.0.0", "v1.1.0-rc.1"}, "", true, "v1.1.0-rc.1"},
+		{[]string{"v1.1.0-rc.1", "v1.1.0-rc.2"}, "", false, "v1.1.0-rc.2"},
+		{[]string{"v1.1.0-rc.1", "v1.0.0", "v0.9.0"}, "", false, "v1.0.0"},
+		{[]string{"v1.0.0", "v2.0.0+incompatible"}, "", false, "v2.0.0+incompatible"},
+		{[]string{"v1.0.0", "v1.1.0"}, "/v2", false, ""},
+		{[]string{"v2.0.0", "v2.1.0", "v3.0.0"}, "/v2", false, "v2.1.0"},
+		{[]string{"v2.0.0", "v2.1.0-beta.1"}, "/v2", true, "v2.1.0-beta.1"},
+	} {
+		if got := maxVersion(tt.vs, tt.pathMajor, tt.pre); got != tt.want {
+			t.Errorf("maxVersion(%q, %q, %t) = %q, want %q", tt.vs, tt.pathMajor, tt.pre, got, tt.want)
+		}
+	}
+}
+
+func TestLatestFallback(t *testing.T) {
+	g := &goList{latest: map[string]listing{
+		"example.com/repo":          {Path: "example.com/repo", Version: "v1.0.0"},
+		"example.com/split/cmd":     {Path: "example.com/split/cmd", Version: "v0.2.0"},
+		"example.com/split/cmd/run": {Path: "example.com/split/cmd/run", Version: "v0.3.0"},
+		"example.com/moved":         {Path: "example.com/moved", Version: "v2.0.0+incompatible"},
+	}}
+	for _, tt := range []struct {
+		mod, pkg          string
+		wantMod, wantVers string
+	}{
+		{"example.com/repo", "example.com/repo/cmd/tool", "example.com/repo", "v1.0.0"},
+		// Innermost first, like go install.
+		{"example.com/split", "example.com/split/cmd/run", "example.com/split/cmd/run", "v0.3.0"},
+		{"example.com/split", "example.com/split/cmd/other", "example.com/split/cmd", "v0.2.0"},
+		// A vanity path that no longer resolves.
+		{"vanity.example.com/moved", "example.com/moved/cmd", "example.com/moved", "v2.0.0+incompatible"},
+		{"example.com/gone", "example.com/gone/cmd/tool", "", ""},
+	} {
+		r := newResolver(false, false, 4, &runner{Runner: g}, nil, nil)
+		mod, l := r.latest(context.Background(), tt.mod, tt.pkg)
+		if mod != tt.wantMod || l.version != tt.wantVers {
+			t.Errorf("latest(%s, %s) = %s %s, %v, want %s %s", tt.mod, tt.pkg, mod, l.version, l.err, tt.wantMod, tt.wantVers)
+		}
+		if tt.wantMod == "" && l.err == nil {
+			t.Errorf("latest(%s, %s): nil error, want the lookup failed", tt.mod, tt.pkg)
+		}
+	}
+}
+
+func TestRetractions(t *testing.T) {
+	g := &goList{latest: map[string]listing{
+		"example.com/tool@v1.0.0":  {Path: "example.com/tool", Version: "v1.0.0", Retracted: []string{"broken", "use v1.0.1"}},
+		"example.com/tool@v1.1.0":  {Path: "example.com/tool", Version: "v1.1.0"},
+		"golang.org/x/vuln@v1.0.0": {Path: "golang.org/x/vuln", Version: "v1.0.0"},
+	}}
+	r := newResolver(false, false, 4, &runner{Runner: g}, nil, nil)
+	got := r.retractions(context.Background(), []string{"example.com/tool@v1.0.0", "golang.org/x/vuln@v1.0.0", "example.com/tool@v1.1.0", "example.com/tool@v1.0.0"})
+	if len(got) != 1 || got["example.com/tool@v1.0.0"] != "broken; use v1.0.1" {
+		t.Errorf("retractions %q, want example.com/tool@v1.0.0 alone", got)
+	}
+	if len(g.runs) != 2 {
+		t.Errorf("ran go list %d times, want once a host", len(g.runs))
+	}
+}
+
+func TestBetween(t *testing.T) {
+	g := &goList{versions: map[string]listing{
+		"example.com/tool": {Path: "example.com/tool", Versions: []string{"v1.0.0", "v1.1.0-rc.1", "v1.1.0", "v1.2.0", "v1.3.0"}},
+	}}
+	r := newResolver(false, false, 4, &runner{Runner: g}, nil, nil)
+	got, err := r.between(context.Background(), "example.com/tool", "v1.0.0", "v1.2.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"v1.1.0-rc.1", "v1.1.0", "v1.2.0"}; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("between v1.0.0 and v1.2.0: %q, want %q", got, want)
+	}
+	if _, err := r.between(context.Background(), "example.com/gone", "v1.0.0", "v1.2.0"); err == nil {
+		t.Errorf("between of a module not listed: nil error")
+	}
+}
+
+// goListed answers every go list -m with the output captured in file.
+type goListed string
+
+func (g goListed) Run(context.Context, []string, ...string) ([]byte, []byte, error) {
+	out, err := os.ReadFile(string(g))
+	return out, nil, err
+}
+
+func TestListFixtures(t *testing.T) {
+	released := time.Date(2024, 3, 4, 10, 0, 0, 0, time.UTC)
+	for _, tt := range []struct {
+		fixture, mod     string
+		version, wantErr string
+		deprecated       string
+	}{
+		{"latest", "example.com/tool", "v1.2.0", "", "use example.com/tool/v2"},
+		// Listed at the version resolved to, with the latest as the
+		// update, as by older go commands, some without -retracted or
+		// deprecations to tell.
+		{"installed", "example.com/tool", "v1.2.0", "", "use example.com/tool/v2"},
+		{"retracted", "example.com/tool", "v1.2.0", "", "use example.com/tool/v2"},
+		{"go1.15", "example.com/tool", "v1.2.0", "", ""},
+		{"gone", "example.com/gone", "", "example.com/gone/@v/list: no such file or directory", ""},
+	} {
+		g := goListed(filepath.Join("testdata", "golist", tt.fixture+".json"))
+		r := newResolver(false, false, 4, &runner{Runner: g}, nil, nil)
+		l := r.latestModule(context.Background(), tt.mod)
+		if tt.wantErr != "" {
+			if l.err == nil || !strings.Contains(l.err.Error(), tt.wantErr) {
+				t.Errorf("%s: error %v, want %q", tt.fixture, l.err, tt.wantErr)
+			}
+			continue
+		}
+		if l.err != nil || l.version != tt.version || l.deprecated != tt.deprecated || !l.released.Equal(released) {
+			t.Errorf("%s: latest %s %q released %s, %v, want %s %q released %s", tt.fixture, l.version, l.deprecated, l.released, l.err, tt.version, tt.deprecated, released)
+		}
+		if l.retracted != "" {
+			t.Errorf("%s: %s retracted, want the update taken", tt.fixture, l.retracted)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package golatest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// Tool declared in a sync manifest.
+type Tool struct {
+	// Path of the package to install.
+	Path string `json:"path"`
+	// Module providing the package, if known.
+	Module string `json:"module,omitempty"`
+	// Version to install when missing, defaults to latest.
+	Version string `json:"version,omitempty"`
+}
+
+// Manifest of tools that should be present in GOBIN, e.g.
+//
+//	{"tools": [
+//	  {"path": "golang.org/x/tools/gopls"},
+//	  {"path": "honnef.co/go/tools/cmd/staticcheck", "version": "v0.4.6"}
+//	]}
+type Manifest struct {
+	Tools []Tool `json:"tools"`
+}
+
+// ReadManifest from file name.
+func ReadManifest(name string) (*Manifest, error) {
+	buf, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	var m Manifest
+	err = json.Unmarshal(buf, &m)
+	if err != nil {
+		return nil, fmt.Errorf("manifest %s: %w", name, err)
+	}
+	for i, t := range m.Tools {
+		if t.Path == "" {
+			return nil, fmt.Errorf("manifest %s: tool %d has no path", name, i)
+		}
+	}
+	return &m, nil
+}
+
+// binaryName go install gives the program built from package pkg.
+// Like go, a trailing major version suffix is skipped: foo/v2 -> foo.
+func binaryName(pkg string) string {
+	name := path.Base(pkg)
+	if isMajorSuffix(name) && path.Dir(pkg) != "." {
+		name = path.Base(path.Dir(pkg))
+	}
+	if runtime.GOOS == "windows" {
+		name += ".exe"
+	}
+	return name
+}
+
+// isMajorSuffix elem of a package path, like go's isVersionElement: v2
+// and up, without leading zeros. Neither v0 nor v1 is ever one.
+func isMajorSuffix(elem string) bool {
+	if len(elem) < 2 || elem[0] != 'v' || elem[1] == '0' || elem[1] == '1' && len(elem) == 2 {
+		return false
+	}
+	return strings.Trim(elem[1:], "0123456789") == ""
+}
+
+// missingTools returns the tools in m that are not already among progs.
+// Tools whose binary name is taken by an unrelated program, or by
+// another tool in m, are returned as conflicts rather than being installed.
+func missingTools(m *Manifest, progs []string) ([]Tool, []Result) {
+	present := map[string]string{}
+	for _, p := range progs {
+		present[filepath.Base(p)] = p
+	}
+
+	var missing []Tool
+	var conflicts []Result
+	claimed := map[string]string{}
+	for _, t := range m.Tools {
+		name := binaryName(t.Path)
+		// What is on disk in the way of t is the file, at current.
+		conflict := func(file, current string, err error) {
+			conflicts = append(conflicts, Result{
+				File:    file,
+				Path:    t.Path,
+				Current: current,
+				Latest:  t.Version,
+				Action:  ActionConflict,
+				Err:     err,
+			})
+		}
+		if other, ok := claimed[name]; ok {
+			conflict("", "", fmt.Errorf("binary %s is also claimed by %s in the manifest", name, other))
+			continue
+		}
+		claimed[name] = t.Path
+
+		file, ok := present[name]
+		if !ok {
+			missing = append(missing, t)
+			continue
+		}
+		info, err := readBuildInfo(file)
+		if err != nil {
+			conflict(file, "", fmt.Errorf("%s is not a Go program: %w", file, err))
+			continue
+		}
+		if info.Path != t.Path {
+			conflict(file, info.Main.Version, fmt.Errorf("%s is already installed from %s", file, info.Path))
+		}
+	}
+	return missing, conflicts
+}
+
+// installTool t into dir, which is assumed to be where go install puts it.
+func installTool(ctx context.Context, run *runner, hosts *keyLimit, dir string, t Tool) Result {
+	res := Result{File: filepath.Join(dir, binaryName(t.Path)), Path: t.Path}
+	release, err := hosts.acquire(ctx, t.Path)
+	if err != nil {
+		return res
+	}
+	defer release()
+
+	version := t.Version
+	if version == "" {
+		version = "latest"
+	}
+	start := time.Now()
+	out, err := run.goStreamed(ctx, nil, binaryName(t.Path), "install", t.Path+"@"+version)
+	res.Duration = time.Since(start)
+	if err != nil {
+		if ctx.Err() != nil {
+			return res
+		}
+		res.Action = ActionError
+		res.Err = fmt.Errorf("go install (%s):\n%s", err, out)
+		return res
+	}
+
+	// Report the version we actually got.
+	res.Latest = version
+	info, err := readBuildInfo(filepath.Join(dir, binaryName(t.Path)))
+	if err == nil {
+		res.Module = info.Main.Path
+		res.Latest = info.Main.Version
+	}
+	if fi, err := os.Stat(res.File); err == nil {
+		res.SizeAfter = fi.Size()
+	}
+	res.Action = ActionInstall
+	return res
+}
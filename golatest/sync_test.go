@@ -0,0 +1,75 @@
+package golatest
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestBinaryName(t *testing.T) {
+	for _, tt := range []struct {
+		pkg, want string
+	}{
+		{"golang.org/x/tools/gopls", "gopls"},
+		{"example.com/tool/v2", "tool"},
+		{"example.com/tool/v10", "tool"},
+		{"example.com/cmd/v1", "v1"},
+		{"example.com/cmd/v0", "v0"},
+		{"example.com/cmd/v02", "v02"},
+		{"example.com/cmd/v2x", "v2x"},
+		{"example.com/cmd/v", "v"},
+		{"v2", "v2"},
+		{"tool", "tool"},
+	} {
+		want := tt.want
+		if runtime.GOOS == "windows" {
+			want += ".exe"
+		}
+		if got := binaryName(tt.pkg); got != want {
+			t.Errorf("binaryName(%q) = %q, want %q", tt.pkg, got, want)
+		}
+	}
+}
+
+func TestMissingTools(t *testing.T) {
+	fakePrograms(t)
+	dir := t.TempDir()
+	program(t, filepath.Join(dir, binaryName("example.com/tool")), prog("example.com/tool", "example.com/tool", "v1.0.0"))
+	program(t, filepath.Join(dir, binaryName("example.com/lint")), prog("example.com/other/lint", "example.com/other", "v0.5.0"))
+	err := os.WriteFile(filepath.Join(dir, binaryName("example.com/script")), []byte("#!/bin/sh\n"), 0o755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	progs, err := listPrograms(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := &Manifest{Tools: []Tool{
+		{Path: "example.com/tool", Version: "v1.2.0"},
+		{Path: "example.com/lint", Version: "v1.0.0"},
+		{Path: "example.com/script"},
+		{Path: "example.com/new"},
+		{Path: "example.com/v2/new", Version: "v2.0.0"},
+	}}
+	missing, conflicts := missingTools(m, progs)
+	if len(missing) != 1 || missing[0].Path != "example.com/new" {
+		t.Errorf("missing %+v, want example.com/new alone", missing)
+	}
+	var got []string
+	for _, r := range conflicts {
+		got = append(got, fmt.Sprintf("%s %s %s %s -> %s", r.Action, r.Path, strings.TrimSuffix(filepath.Base(r.File), ".exe"), r.Current, r.Latest))
+	}
+	// As installed, then as pinned.
+	want := []string{
+		"conflict example.com/lint lint v0.5.0 -> v1.0.0",
+		"conflict example.com/script script  -> ",
+		"conflict example.com/v2/new .  -> v2.0.0",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("conflicts\n%q, want\n%q", got, want)
+	}
+}
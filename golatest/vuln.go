@@ -0,0 +1,277 @@
+package golatest
+
+import (
+	"bytes"
+	"context"
+	"debug/buildinfo"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/mod/semver"
+	"golang.org/x/sync/errgroup"
+)
+
+// DefaultVulnDB is the OSV API of the Go vulnerability database, as
+// used by govulncheck.
+const DefaultVulnDB = "https://api.osv.dev"
+
+// Vuln is a known vulnerability of a module version a program was built
+// with, the main module, a dependency or the standard library as "stdlib".
+type Vuln struct {
+	// ID of the advisory, e.g. GO-2024-2687.
+	ID      string `json:"id"`
+	Module  string `json:"module"`
+	Version string `json:"version"`
+	// Fixed is the version of Module fixing it, if any.
+	Fixed string `json:"fixed,omitempty"`
+}
+
+// Fixes reports whether upgrading as in r fixes v, as far as is known
+// without the dependencies of Latest.
+func (r Result) Fixes(v Vuln) bool {
+	if v.Fixed == "" {
+		return false
+	}
+	switch {
+	case v.Module == "stdlib":
+		return r.GoLatest != "" && semver.Compare("v"+strings.TrimPrefix(r.GoLatest, "go"), v.Fixed) >= 0
+	case v.Module == r.Module && r.Latest != r.Current:
+		return semver.Compare(r.Latest, v.Fixed) >= 0
+	}
+	return false
+}
+
+// fixed vulnerabilities of the main module of r, by upgrading it.
+func (r Result) fixed() []Vuln {
+	var fixed []Vuln
+	for _, v := range r.Vulns {
+		if v.Module == r.Module && r.Fixes(v) {
+			fixed = append(fixed, v)
+		}
+	}
+	return fixed
+}
+
+// batchQueries is the most queries of a single OSV request.
+const batchQueries = 1000
+
+// vulnDB looks up vulnerabilities in an OSV API, remembering what it
+// found in cache if set.
+type vulnDB struct {
+	url    string
+	client *http.Client
+	cache  *Cache
+	// workers looking up vulnerabilities at once.
+	workers int
+}
+
+// vulns of each of the programs described by infos, by module version.
+func (db *vulnDB) vulns(ctx context.Context, infos []*buildinfo.BuildInfo) ([][]Vuln, error) {
+	var mvs []string
+	for _, info := range infos {
+		mvs = append(mvs, moduleVersions(info)...)
+	}
+	ids, err := db.query(ctx, compact(mvs))
+	if err != nil {
+		return nil, err
+	}
+	var all []string
+	for _, vs := range ids {
+		all = append(all, vs...)
+	}
+	fixes, err := db.fixes(ctx, compact(all))
+	if err != nil {
+		return nil, err
+	}
+
+	vulns := make([][]Vuln, len(infos))
+	for i, info := range infos {
+		for _, mv := range moduleVersions(info) {
+			mod, v, _ := strings.Cut(mv, "@")
+			for _, id := range ids[mv] {
+				vulns[i] = append(vulns[i], Vuln{ID: id, Module: mod, Version: v, Fixed: fixedIn(fixes[id][mod], v)})
+			}
+		}
+	}
+	return vulns, nil
+}
+
+// moduleVersions a program was built with, mod@version each, those
+// that are not released versions left out.
+func moduleVersions(info *buildinfo.BuildInfo) []string {
+	var mvs []string
+	if v := "v" + strings.TrimPrefix(info.GoVersion, "go"); semver.IsValid(v) {
+		mvs = append(mvs, "stdlib@"+v)
+	}
+	mods := append([]*debug.Module{&info.Main}, info.Deps...)
+	for _, m := range mods {
+		if m.Replace != nil {
+			m = m.Replace
+		}
+		if m.Path != "" && semver.IsValid(m.Version) && semver.Build(m.Version) == "" {
+			mvs = append(mvs, m.Path+"@"+m.Version)
+		}
+	}
+	return mvs
+}
+
+// query the IDs of the vulnerabilities of each of mvs, mod@version each.
+func (db *vulnDB) query(ctx context.Context, mvs []string) (map[string][]string, error) {
+	ids := map[string][]string{}
+	var todo []string
+	for _, mv := range mvs {
+		var cached []string
+		if db.cache != nil && db.cache.getData("osv/"+mv, &cached) {
+			ids[mv] = cached
+			continue
+		}
+		todo = append(todo, mv)
+	}
+	for len(todo) > 0 {
+		batch := todo[:min(len(todo), batchQueries)]
+		todo = todo[len(batch):]
+
+		type query struct {
+			Package struct {
+				Name      string `json:"name"`
+				Ecosystem string `json:"ecosystem"`
+			} `json:"package"`
+			Version string `json:"version"`
+		}
+		var req struct {
+			Queries []query `json:"queries"`
+		}
+		for _, mv := range batch {
+			var q query
+			mod, v, _ := strings.Cut(mv, "@")
+			// OSV has Go versions without the v.
+			q.Package.Name, q.Package.Ecosystem, q.Version = mod, "Go", strings.TrimPrefix(v, "v")
+			req.Queries = append(req.Queries, q)
+		}
+		var resp struct {
+			Results []struct {
+				Vulns []struct {
+					ID string `json:"id"`
+				} `json:"vulns"`
+			} `json:"results"`
+		}
+		err := db.do(ctx, http.MethodPost, "/v1/querybatch", req, &resp)
+		if err != nil {
+			return nil, err
+		}
+		if len(resp.Results) != len(batch) {
+			return nil, fmt.Errorf("osv: %d results for %d queries", len(resp.Results), len(batch))
+		}
+		for i, mv := range batch {
+			found := []string{}
+			for _, v := range resp.Results[i].Vulns {
+				found = append(found, v.ID)
+			}
+			ids[mv] = found
+			if db.cache != nil {
+				db.cache.putData("osv/"+mv, found)
+			}
+		}
+	}
+	return ids, nil
+}
+
+// fixes of the vulnerabilities ids, the versions fixing them by module.
+func (db *vulnDB) fixes(ctx context.Context, ids []string) (map[string]map[string][]string, error) {
+	var mu sync.Mutex
+	fixes := map[string]map[string][]string{}
+	var eg errgroup.Group
+	eg.SetLimit(db.workers)
+	for _, id := range ids {
+		id := id
+		eg.Go(func() error {
+			var fixed map[string][]string
+			if db.cache == nil || !db.cache.getData("osv/"+id, &fixed) {
+				var osv struct {
+					Affected []struct {
+						Package struct {
+							Name string `json:"name"`
+						} `json:"package"`
+						Ranges []struct {
+							Events []struct {
+								Fixed string `json:"fixed"`
+							} `json:"events"`
+						} `json:"ranges"`
+					} `json:"affected"`
+				}
+				err := db.do(ctx, http.MethodGet, "/v1/vulns/"+id, nil, &osv)
+				if err != nil {
+					return err
+				}
+				fixed = map[string][]string{}
+				for _, a := range osv.Affected {
+					for _, r := range a.Ranges {
+						for _, e := range r.Events {
+							if e.Fixed != "" {
+								fixed[a.Package.Name] = append(fixed[a.Package.Name], "v"+e.Fixed)
+							}
+						}
+					}
+				}
+				if db.cache != nil {
+					db.cache.putData("osv/"+id, fixed)
+				}
+			}
+			mu.Lock()
+			fixes[id] = fixed
+			mu.Unlock()
+			return nil
+		})
+	}
+	return fixes, eg.Wait()
+}
+
+// fixedIn the first of fixes after version v, or empty if none is.
+func fixedIn(fixes []string, v string) string {
+	first := ""
+	for _, f := range fixes {
+		if semver.Compare(f, v) > 0 && (first == "" || semver.Compare(f, first) < 0) {
+			first = f
+		}
+	}
+	return first
+}
+
+// do an OSV API request to path with the JSON of body, if any, decoding
+// the JSON response into resp.
+func (db *vulnDB) do(ctx context.Context, method, path string, body, resp any) error {
+	var buf bytes.Buffer
+	if body != nil {
+		err := json.NewEncoder(&buf).Encode(body)
+		if err != nil {
+			return err
+		}
+	}
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimSuffix(db.url, "/")+path, &buf)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	res, err := db.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("osv: %w", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("osv: %s %s: %s", method, path, res.Status)
+	}
+	err = json.NewDecoder(res.Body).Decode(resp)
+	if err != nil {
+		return fmt.Errorf("osv: %s: %v", path, err)
+	}
+	return nil
+}
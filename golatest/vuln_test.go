@@ -0,0 +1,224 @@
+package golatest
+
+import (
+	"context"
+	"debug/buildinfo"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// osv is a fake OSV API, with the IDs of the vulnerabilities of each
+// mod@version, without the v like OSV has versions, and the versions
+// fixing each vulnerability by module. It records the number of queries in
+// each batch, and fails every request with status if set.
+type osv struct {
+	ids    map[string][]string
+	fixed  map[string]map[string][]string
+	status int
+
+	mu      sync.Mutex
+	batches []int
+}
+
+func (o *osv) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if o.status != 0 {
+		http.Error(w, "down", o.status)
+		return
+	}
+	switch {
+	case r.Method == http.MethodPost && r.URL.Path == "/v1/querybatch":
+		var req struct {
+			Queries []struct {
+				Package struct {
+					Name, Ecosystem string
+				}
+				Version string
+			}
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		o.mu.Lock()
+		o.batches = append(o.batches, len(req.Queries))
+		o.mu.Unlock()
+		type vuln struct {
+			ID string `json:"id"`
+		}
+		type result struct {
+			Vulns []vuln `json:"vulns,omitempty"`
+		}
+		var resp struct {
+			Results []result `json:"results"`
+		}
+		for _, q := range req.Queries {
+			var res result
+			if q.Package.Ecosystem == "Go" {
+				for _, id := range o.ids[q.Package.Name+"@"+q.Version] {
+					res.Vulns = append(res.Vulns, vuln{id})
+				}
+			}
+			resp.Results = append(resp.Results, res)
+		}
+		json.NewEncoder(w).Encode(resp)
+	case r.Method == http.MethodGet && strings.HasPrefix(r.URL.Path, "/v1/vulns/"):
+		id := strings.TrimPrefix(r.URL.Path, "/v1/vulns/")
+		fixed, ok := o.fixed[id]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		type event struct {
+			Fixed string `json:"fixed,omitempty"`
+		}
+		type affected struct {
+			Package struct {
+				Name string `json:"name"`
+			} `json:"package"`
+			Ranges []struct {
+				Events []event `json:"events"`
+			} `json:"ranges"`
+		}
+		var resp struct {
+			Affected []affected `json:"affected"`
+		}
+		for mod, vs := range fixed {
+			var a affected
+			a.Package.Name = mod
+			for _, v := range vs {
+				// Each range introduced, then fixed.
+				a.Ranges = append(a.Ranges, struct {
+					Events []event `json:"events"`
+				}{[]event{{}, {Fixed: v}}})
+			}
+			resp.Affected = append(resp.Affected, a)
+		}
+		json.NewEncoder(w).Encode(resp)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// vulnerable is the OSV of a tool and the Go it was built with, each
+// fixed in two branches.
+func vulnerable() *osv {
+	return &osv{
+		ids: map[string][]string{
+			"stdlib@1.22.0":           {"GO-2024-0001"},
+			"example.com/tool@1.0.0":  {"GO-2024-0002"},
+			"example.com/dep@0.1.0":   {"GO-2024-0003"},
+			"example.com/other@2.0.0": nil,
+		},
+		fixed: map[string]map[string][]string{
+			"GO-2024-0001": {"stdlib": {"1.21.9", "1.22.2"}},
+			"GO-2024-0002": {"example.com/tool": {"1.1.0", "1.0.3"}},
+			// Fixed in none yet.
+			"GO-2024-0003": {},
+		},
+	}
+}
+
+func TestVulns(t *testing.T) {
+	srv := httptest.NewServer(vulnerable())
+	defer srv.Close()
+	tool := prog("example.com/tool", "example.com/tool", "v1.0.0")
+	tool.Deps = []*debug.Module{{Path: "example.com/dep", Version: "v0.1.0"}}
+	other := prog("example.com/other/v2", "example.com/other", "v2.0.0")
+	// Neither built with a Go release, nor at a version of its own.
+	local := &buildinfo.BuildInfo{GoVersion: "devel go1.23-abcdef", Path: "example.com/local", Main: debug.Module{Path: "example.com/local", Version: "(devel)"}}
+
+	db := &vulnDB{url: srv.URL, client: srv.Client(), workers: 4}
+	got, err := db.vulns(context.Background(), []*buildinfo.BuildInfo{tool, other, local})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]Vuln{
+		{
+			{ID: "GO-2024-0001", Module: "stdlib", Version: "v1.22.0", Fixed: "v1.22.2"},
+			{ID: "GO-2024-0002", Module: "example.com/tool", Version: "v1.0.0", Fixed: "v1.0.3"},
+			{ID: "GO-2024-0003", Module: "example.com/dep", Version: "v0.1.0"},
+		},
+		{{ID: "GO-2024-0001", Module: "stdlib", Version: "v1.22.0", Fixed: "v1.22.2"}},
+		nil,
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("vulns\n%v, want\n%v", got, want)
+	}
+}
+
+func TestVulnsBatches(t *testing.T) {
+	o := &osv{}
+	srv := httptest.NewServer(o)
+	defer srv.Close()
+	info := prog("example.com/tool", "example.com/tool", "v1.0.0")
+	for i := 0; i < batchQueries; i++ {
+		info.Deps = append(info.Deps, &debug.Module{Path: fmt.Sprintf("example.com/dep%d", i), Version: "v1.0.0"})
+	}
+	db := &vulnDB{url: srv.URL, client: srv.Client(), workers: 4}
+	_, err := db.vulns(context.Background(), []*buildinfo.BuildInfo{info})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// stdlib, the tool and its deps.
+	if want := fmt.Sprint([]int{batchQueries, 2}); fmt.Sprint(o.batches) != want {
+		t.Errorf("queried in batches of %v, want %s", o.batches, want)
+	}
+}
+
+func TestUpgradeVulns(t *testing.T) {
+	fakePrograms(t)
+	for _, tt := range []struct {
+		name   string
+		status int
+		opts   Options
+		latest string
+		want   string
+	}{
+		{"fixed", 0, Options{OnlyVulnerable: true}, "v1.0.3", "upgrade v1.0.3"},
+		{"fixed in a newer branch", 0, Options{OnlyVulnerable: true}, "v1.2.0", "upgrade v1.2.0"},
+		{"not fixed", 0, Options{OnlyVulnerable: true}, "v1.0.2", "held v1.0.2"},
+		{"unknown", http.StatusServiceUnavailable, Options{Vulns: true}, "v1.0.2", "upgrade v1.0.2 unknown"},
+		{"unknown, only vulnerable", http.StatusServiceUnavailable, Options{OnlyVulnerable: true}, "v1.0.2", "failed"},
+	} {
+		o := vulnerable()
+		o.status = tt.status
+		srv := httptest.NewServer(o)
+		dir := t.TempDir()
+		program(t, filepath.Join(dir, "tool"), prog("example.com/tool", "example.com/tool", "v1.0.0"))
+		g := &goInstall{t: t, dir: dir, list: &goList{latest: map[string]listing{
+			"example.com/tool": {Path: "example.com/tool", Version: tt.latest},
+		}}}
+		tt.opts.Dir, tt.opts.Runner, tt.opts.VulnDB = dir, g, srv.URL
+		results, err := New(tt.opts).Upgrade(context.Background())
+		srv.Close()
+
+		var got string
+		switch {
+		case err != nil && strings.Contains(err.Error(), "vulnerabilities unknown, upgrading nothing"):
+			got = "failed"
+		case err != nil:
+			t.Fatalf("%s: Upgrade: %v", tt.name, err)
+		case len(results) != 1:
+			t.Fatalf("%s: got %d results, want 1", tt.name, len(results))
+		default:
+			r := results[0]
+			got = r.Action + " " + r.Latest
+			if r.VulnErr != nil {
+				got += " unknown"
+			}
+		}
+		if got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+		if tt.want == "failed" && len(g.installed()) > 0 {
+			t.Errorf("%s: installed %q, want nothing", tt.name, g.installed())
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"golang.org/x/term"
+)
+
+// isTerminal reports whether f is a file connected to a terminal.
+func isTerminal(f any) bool {
+	file, ok := f.(*os.File)
+	return ok && term.IsTerminal(int(file.Fd()))
+}
+
+// prompter asks for confirmation of upgrades one at a time,
+// workers waiting for an answer queue up behind each other.
+type prompter struct {
+	mu  sync.Mutex
+	in  *bufio.Reader
+	out io.Writer
+	// all remaining upgrades are confirmed.
+	all bool
+	// quit declines all remaining upgrades.
+	quit bool
+}
+
+func newPrompter(in io.Reader, out io.Writer) *prompter {
+	return &prompter{in: bufio.NewReader(in), out: out}
+}
+
+// confirm the upgrade described by what.
+func (p *prompter) confirm(what string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.quit {
+		return false
+	}
+	if p.all {
+		return true
+	}
+	for {
+		fmt.Fprintf(p.out, "%s [y,n,a,q]? ", what)
+		line, err := p.in.ReadString('\n')
+		if err != nil {
+			// Treat a closed input as quitting, lest we spin.
+			fmt.Fprintln(p.out)
+			p.quit = true
+			return false
+		}
+		switch strings.ToLower(strings.TrimSpace(line)) {
+		case "y", "yes":
+			return true
+		case "n", "no":
+			return false
+		case "a", "all":
+			p.all = true
+			return true
+		case "q", "quit":
+			p.quit = true
+			return false
+		}
+		fmt.Fprintln(p.out, "y - upgrade, n - don't upgrade, a - upgrade this and all remaining, q - quit")
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/vikblom/go-latest/golatest"
+)
+
+// jsonStream writes events to a stream of JSON lines as they happen, e.g.
+//
+//	{"event":"scan-started","total":12}
+//	{"event":"binary-resolved","result":{"path":"golang.org/x/tools/gopls",...}}
+//	{"event":"install-started","result":{...}}
+//	{"event":"install-finished","result":{...}}
+//	{"event":"run-finished","counts":{"upgrade":1,"latest":11},"failed":0,"duration_ms":12345}
+//
+// Results are those of -json, so far.
+type jsonStream struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+func newJSONStream(w io.Writer) *jsonStream {
+	return &jsonStream{enc: json.NewEncoder(w)}
+}
+
+type jsonEvent struct {
+	Event      string           `json:"event"`
+	Total      int              `json:"total,omitempty"`
+	Result     *golatest.Result `json:"result,omitempty"`
+	Counts     map[string]int   `json:"counts,omitempty"`
+	Failed     *int             `json:"failed,omitempty"`
+	DurationMS int64            `json:"duration_ms,omitempty"`
+}
+
+func (j *jsonStream) event(e golatest.Event) {
+	var je jsonEvent
+	switch e.Kind {
+	case golatest.EventResolvePhase:
+		je = jsonEvent{Event: "scan-started", Total: e.Total}
+	case golatest.EventResolved:
+		je = jsonEvent{Event: "binary-resolved", Result: &e.Result}
+	case golatest.EventInstalling:
+		je = jsonEvent{Event: "install-started", Result: &e.Result}
+	case golatest.EventInstalled:
+		je = jsonEvent{Event: "install-finished", Result: &e.Result}
+	default:
+		return
+	}
+	j.write(je)
+}
+
+// finished run with results after took.
+func (j *jsonStream) finished(results []golatest.Result, took time.Duration) {
+	je := jsonEvent{Event: "run-finished", Counts: map[string]int{}, DurationMS: took.Milliseconds()}
+	failed := 0
+	for _, r := range results {
+		if r.Action == "" {
+			continue
+		}
+		je.Counts[r.Action]++
+		if r.Action == golatest.ActionError || r.Action == golatest.ActionConflict {
+			failed++
+		}
+	}
+	je.Failed = &failed
+	j.write(je)
+}
+
+func (j *jsonStream) write(je jsonEvent) {
+	// Encode writes each line at once, the lock keeps them in order.
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.enc.Encode(je)
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/vikblom/go-latest/golatest"
+)
+
+// listed program, as list -json prints it.
+type listed struct {
+	File      string `json:"file"`
+	Path      string `json:"path"`
+	Module    string `json:"module,omitempty"`
+	Version   string `json:"version"`
+	GoVersion string `json:"go_version"`
+	Revision  string `json:"vcs_revision,omitempty"`
+	Time      string `json:"vcs_time,omitempty"`
+	Modified  bool   `json:"vcs_modified,omitempty"`
+}
+
+// list the programs installed to dir, their versions and the version of
+// Go that built them, without looking anything up:
+//
+//	golang.org/x/tools/gopls  v0.15.1  go1.22.0
+//	example.com/mytool        (devel)  go1.22.0  [abc1234 2024-03-02, dirty]
+func list(w io.Writer, jsonOut bool, dir string) error {
+	results, err := golatest.List(dir)
+	if err != nil {
+		return err
+	}
+	if jsonOut {
+		ls := []listed{}
+		for _, r := range results {
+			ls = append(ls, listed{
+				File:      r.File,
+				Path:      r.Path,
+				Module:    r.Module,
+				Version:   r.Current,
+				GoVersion: r.GoCurrent,
+				Revision:  r.Revision,
+				Time:      r.RevisionTime,
+				Modified:  r.Modified,
+			})
+		}
+		return json.NewEncoder(w).Encode(ls)
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, r := range results {
+		fmt.Fprintf(tw, "%s\t%s\t%s", r.Path, r.Current, r.GoCurrent)
+		if v := vcs(r); v != "" {
+			fmt.Fprintf(tw, "\t%s", v)
+		}
+		fmt.Fprintln(tw)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+)
+
+// newLogger writing to w in the given format at the given minimum level.
+// Only the text format is ever colored.
+func newLogger(w io.Writer, format, level string, color bool) (*slog.Logger, error) {
+	var lvl slog.Level
+	err := lvl.UnmarshalText([]byte(level))
+	if err != nil {
+		return nil, fmt.Errorf("log level: %w", err)
+	}
+	opts := &slog.HandlerOptions{Level: lvl}
+
+	switch format {
+	case "text":
+		return slog.New(newHumanHandler(w, opts, color)), nil
+	case "json":
+		return slog.New(slog.NewJSONHandler(w, opts)), nil
+	}
+	return nil, fmt.Errorf("unknown log format %q", format)
+}
+
+// humanHandler prints records the way go-latest always has, one line each:
+//
+//	golang.org/x/tools/gopls v0.9.5 -> v0.10.0 (12.3s)
+//	golang.org/x/tools/cmd/stringer v0.3.0 already latest
+//	honnef.co/go/tools/cmd/staticcheck installed v0.4.6 (4.1s)
+//
+// Attributes that have no place in that format are dropped, except for
+// errors which are appended to the line.
+type humanHandler struct {
+	opts  slog.HandlerOptions
+	attrs []slog.Attr
+	// color the outcome of each program.
+	color bool
+
+	mu *sync.Mutex
+	w  io.Writer
+}
+
+func newHumanHandler(w io.Writer, opts *slog.HandlerOptions, color bool) *humanHandler {
+	h := &humanHandler{mu: &sync.Mutex{}, w: w, color: color}
+	if opts != nil {
+		h.opts = *opts
+	}
+	return h
+}
+
+func (h *humanHandler) Enabled(_ context.Context, l slog.Level) bool {
+	min := slog.LevelInfo
+	if h.opts.Level != nil {
+		min = h.opts.Level.Level()
+	}
+	return l >= min
+}
+
+func (h *humanHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	hh := *h
+	hh.attrs = append(h.attrs[:len(h.attrs):len(h.attrs)], attrs...)
+	return &hh
+}
+
+// WithGroup is a no-op, groups have no meaning in the human format.
+func (h *humanHandler) WithGroup(string) slog.Handler {
+	return h
+}
+
+func (h *humanHandler) Handle(_ context.Context, r slog.Record) error {
+	attrs := make([]slog.Attr, 0, len(h.attrs)+r.NumAttrs())
+	attrs = append(attrs, h.attrs...)
+	r.Attrs(func(a slog.Attr) bool {
+		attrs = append(attrs, a)
+		return true
+	})
+	// take the value of key, removing it from attrs.
+	take := func(key string) string {
+		for i, a := range attrs {
+			if a.Key == key {
+				attrs = append(attrs[:i], attrs[i+1:]...)
+				return a.Value.String()
+			}
+		}
+		return ""
+	}
+
+	var b strings.Builder
+	// file and pkg of the program the record is of, if any.
+	var file, pkg string
+	if p := take("path"); p != "" {
+		b.WriteString(p)
+		current, latest := take("current"), take("latest")
+		// Why it's upgraded, if not by the version.
+		if goCurrent, goLatest := take("go_current"), take("go_latest"); goLatest != "" {
+			current += " (" + goCurrent + ")"
+			latest += " (" + goLatest + ")"
+		}
+		if current != "" {
+			b.WriteString(" " + current)
+		}
+		b.WriteString(" ")
+
+		released, duration, size, attempts := take("released"), take("duration"), take("size"), take("attempts")
+		paint := h.paint
+		switch action := take("action"); action {
+		case "upgrade":
+			b.WriteString(paint(colorGreen, "-> ") + paint(colorGreen+colorBold, latest) + took(released, duration, size, attempts))
+		case "install":
+			b.WriteString(paint(colorGreen, r.Message+" ") + paint(colorGreen+colorBold, latest) + took(released, duration, size, attempts))
+		case "reinstall":
+			b.WriteString(paint(colorGreen, r.Message) + took("", duration, size, attempts))
+		case "removed":
+			b.WriteString(paint(colorGreen, r.Message))
+		case "skip", "latest", "declined":
+			b.WriteString(paint(colorDim, r.Message))
+		default:
+			b.WriteString(paint(levelColor(r.Level), r.Message))
+		}
+		// Covered by the path already, but for naming its output.
+		take("module")
+		file, pkg = take("file"), p
+	} else {
+		b.WriteString(r.Message)
+	}
+
+	var errMsg, versions, changes string
+	var vulns []string
+	for _, a := range attrs {
+		switch a.Key {
+		case "err":
+			errMsg = strings.TrimSpace(a.Value.String())
+			continue
+		case "versions":
+			versions = a.Value.String()
+			continue
+		case "changes":
+			changes = a.Value.String()
+			continue
+		case "vulns":
+			if vs, ok := a.Value.Any().([]string); ok {
+				vulns = vs
+				continue
+			}
+		}
+		fmt.Fprintf(&b, " %s=%s", a.Key, a.Value)
+	}
+	first, rest, _ := strings.Cut(errMsg, "\n")
+	if first != "" {
+		b.WriteString(": " + first)
+	}
+	for _, v := range vulns {
+		b.WriteString("\n    " + h.paint(colorYellow, v))
+	}
+	if versions != "" {
+		b.WriteString("\n    " + h.paint(colorDim, versions))
+	}
+	if changes != "" {
+		b.WriteString("\n    " + h.paint(colorDim, changes))
+	}
+	b.WriteString("\n")
+	if rest != "" {
+		// Output of go spanning lines goes last, as a block of its own
+		// after the name of the program it's of, so it can't be
+		// mistaken for that of another. The record is a single write.
+		if pkg != "" {
+			b.WriteString(diagnostics(file, pkg, rest))
+		} else {
+			b.WriteString("    " + strings.ReplaceAll(rest, "\n", "\n    ") + "\n")
+		}
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, err := io.WriteString(h.w, b.String())
+	return err
+}
+
+// took formats a duration attribute for humans, along with the release
+// time and notes like the size change if any, e.g. " (12.3s)" or
+// " (released 3 weeks ago, 12.3s, +1.2 MB)".
+func took(released, duration string, notes ...string) string {
+	var parts []string
+	if t, err := time.Parse(time.RFC3339, released); err == nil {
+		parts = append(parts, "released "+ago(time.Since(t)))
+	}
+	if d, err := time.ParseDuration(duration); err == nil && d != 0 {
+		parts = append(parts, d.Round(100*time.Millisecond).String())
+	}
+	for _, n := range notes {
+		if n != "" {
+			parts = append(parts, n)
+		}
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return " (" + strings.Join(parts, ", ") + ")"
+}
+
+func (h *humanHandler) paint(color, s string) string {
+	return paint(h.color, color, s)
+}
+
+// paint s in color, if on.
+func paint(on bool, color, s string) string {
+	if !on || color == "" || s == "" {
+		return s
+	}
+	return color + s + colorReset
+}
+
+func levelColor(l slog.Level) string {
+	switch {
+	case l >= slog.LevelError:
+		return colorRed
+	case l >= slog.LevelWarn:
+		return colorYellow
+	}
+	return ""
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHumanHandlerLevel(t *testing.T) {
+	for _, tt := range []struct {
+		level string
+		want  string
+	}{
+		{"debug", "debug\ninfo\nwarn\nerror\n"},
+		{"info", "info\nwarn\nerror\n"},
+		{"warn", "warn\nerror\n"},
+		{"error", "error\n"},
+	} {
+		var b strings.Builder
+		log, err := newLogger(&b, "text", tt.level, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		log.Debug("debug")
+		log.Info("info")
+		log.Warn("warn")
+		log.Error("error")
+		if b.String() != tt.want {
+			t.Errorf("level %s: got %q, want %q", tt.level, b.String(), tt.want)
+		}
+	}
+}
+
+func TestHumanHandlerAttrs(t *testing.T) {
+	for _, tt := range []struct {
+		name  string
+		msg   string
+		attrs []any
+		want  string
+	}{
+		{
+			name:  "upgrade",
+			msg:   "upgraded",
+			attrs: []any{"path", "example.com/tool", "module", "example.com/tool", "current", "v1.0.0", "latest", "v1.1.0", "action", "upgrade", "duration", 1234 * time.Millisecond},
+			want:  "example.com/tool v1.0.0 -> v1.1.0 (1.2s)\n",
+		},
+		{
+			name:  "install",
+			msg:   "installed",
+			attrs: []any{"path", "example.com/tool", "latest", "v1.1.0", "action", "install"},
+			want:  "example.com/tool installed v1.1.0\n",
+		},
+		{
+			name:  "go upgrade",
+			msg:   "reinstalled",
+			attrs: []any{"path", "example.com/tool", "current", "v1.0.0", "go_current", "go1.21.0", "go_latest", "go1.22.0", "latest", "v1.0.0", "action", "upgrade"},
+			want:  "example.com/tool v1.0.0 (go1.21.0) -> v1.0.0 (go1.22.0)\n",
+		},
+		{
+			name:  "latest",
+			msg:   "already latest",
+			attrs: []any{"path", "example.com/tool", "current", "v1.0.0", "action", "latest", "file", "/bin/tool"},
+			want:  "example.com/tool v1.0.0 already latest\n",
+		},
+		{
+			name:  "other attrs",
+			msg:   "summary",
+			attrs: []any{"upgraded", 2, "failed", 1},
+			want:  "summary upgraded=2 failed=1\n",
+		},
+		{
+			name:  "error",
+			msg:   "failed",
+			attrs: []any{"path", "example.com/tool", "current", "v1.0.0", "action", "error", "err", errors.New("go install (exit status 1):\nline 1\nline 2\n")},
+			want:  "example.com/tool v1.0.0 failed: go install (exit status 1):\n=== tool ===\nline 1\nline 2\n",
+		},
+		{
+			name:  "error of a file",
+			msg:   "failed",
+			attrs: []any{"path", "example.com/tool/v2", "file", "/bin/tool-v2", "action", "error", "err", errors.New("go install (exit status 1):\nline 1"), "vulns", []string{"GO-2024-0001"}},
+			want:  "example.com/tool/v2 failed: go install (exit status 1):\n    GO-2024-0001\n=== tool-v2 ===\nline 1\n",
+		},
+		{
+			name:  "error of no program",
+			msg:   "vulnerability status unknown",
+			attrs: []any{"err", errors.New("osv:\nline 1\nline 2")},
+			want:  "vulnerability status unknown: osv:\n    line 1\n    line 2\n",
+		},
+		{
+			name:  "versions and changes",
+			msg:   "upgraded",
+			attrs: []any{"path", "example.com/tool", "current", "v1.0.0", "latest", "v1.2.0", "action", "upgrade", "versions", "2 releases: v1.1.0 v1.2.0", "changes", "https://example.com/compare"},
+			want:  "example.com/tool v1.0.0 -> v1.2.0\n    2 releases: v1.1.0 v1.2.0\n    https://example.com/compare\n",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var b strings.Builder
+			log := slog.New(newHumanHandler(&b, nil, false))
+			log.Info(tt.msg, tt.attrs...)
+			if b.String() != tt.want {
+				t.Errorf("got %q, want %q", b.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestHumanHandlerWithAttrs(t *testing.T) {
+	var b strings.Builder
+	log := slog.New(newHumanHandler(&b, nil, false)).With("path", "example.com/tool", "current", "v1.0.0")
+	log.Info("already latest", "action", "latest")
+	log.WithGroup("ignored").Warn("retracted", "rationale", "broken")
+	want := "example.com/tool v1.0.0 already latest\nexample.com/tool v1.0.0 retracted rationale=broken\n"
+	if b.String() != want {
+		t.Errorf("got %q, want %q", b.String(), want)
+	}
+}
+
+func TestHumanHandlerColor(t *testing.T) {
+	var b strings.Builder
+	log := slog.New(newHumanHandler(&b, nil, true))
+	log.Info("upgraded", "path", "example.com/tool", "current", "v1.0.0", "latest", "v1.1.0", "action", "upgrade")
+	want := "example.com/tool v1.0.0 " + colorGreen + "-> " + colorReset + colorGreen + colorBold + "v1.1.0" + colorReset + "\n"
+	if b.String() != want {
+		t.Errorf("got %q, want %q", b.String(), want)
+	}
+}
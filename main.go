@@ -1,239 +1,858 @@
 // go-latest tries to upgrade programs go install-d to GOBIN.
-//
-// For reference, go itself:
-// ./src/cmd/go/internal/version/version.go
-// ./src/cmd/go/internal/work/build.go
 package main
 
 import (
-	"bytes"
 	"context"
-	"debug/buildinfo"
 	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"io/fs"
+	"log/slog"
+	"net/http"
+	"net/url"
 	"os"
-	"os/exec"
 	"os/signal"
 	"path/filepath"
 	"runtime"
 	"runtime/debug"
+	"slices"
+	"strings"
+	"syscall"
+	"text/template"
+	"time"
 
-	"golang.org/x/mod/semver"
-	"golang.org/x/sync/errgroup"
+	"github.com/vikblom/go-latest/golatest"
 )
 
-// NOTES:
-// golang.org/x/mod/semver
-// golang.org/x/mod/module
-//
-// Use:
-// go list -m -json golang.org/x/tools/gopls@latest
-// either on each pkg or on the module.
+// usageError is in how go-latest was run, rather than anything it did.
+type usageError struct{ error }
 
-func gobin() string {
-	gobin := os.Getenv("GOBIN")
-	if gobin != "" {
-		return gobin
-	}
-	home := os.Getenv("HOME")
-	if home != "" {
-		return filepath.Join(home, "go", "bin")
-	}
-	return ""
-}
+func (e usageError) Unwrap() error { return e.error }
 
-func goversion(ctx context.Context) (string, error) {
-	cmd := exec.CommandContext(ctx, "go", "env", "GOVERSION")
-	out, err := cmd.CombinedOutput()
-	if err != nil {
-		return "", fmt.Errorf("go env (%w):\n%s", err, out)
-	}
+// timeoutError is running out of -timeout before the run was done.
+type timeoutError struct{ error }
 
-	return string(bytes.TrimSpace(out)), nil
-}
+func (e timeoutError) Unwrap() error { return e.error }
 
-func listPrograms(dir string) ([]string, error) {
-	files, err := os.ReadDir(dir)
-	if err != nil {
-		return nil, err
-	}
-	var programs []string
-	for _, de := range files {
-		fi, err := de.Info()
-		if err != nil {
-			return nil, fmt.Errorf("info %q: %w", de.Name(), err)
-		}
-		if isExecutable(fi) {
-			programs = append(programs, filepath.Join(dir, fi.Name()))
-		}
-	}
-	return programs, nil
-}
+// Exit statuses.
+const (
+	exitFailed      = 1
+	exitUsage       = 2
+	exitTimeout     = 124
+	exitInterrupted = 130
+)
 
-func isExecutable(fi fs.FileInfo) bool {
-	return fi.Mode().Perm()&0111 != 0
+// exitStatus for the error runMain returned.
+func exitStatus(err error) int {
+	var usage usageError
+	var timeout timeoutError
+	switch {
+	case err == nil:
+		return 0
+	case errors.As(err, &usage):
+		return exitUsage
+	case errors.As(err, &timeout):
+		return exitTimeout
+	case errors.Is(err, context.Canceled):
+		return exitInterrupted
+	}
+	return exitFailed
 }
 
-// isSpecific revision installed from local repo or a specific SHA.
-// In other words not some generally available package installed with @latest.
-func isSpecific(v string) bool {
-	// Local
-	if v == "(devel)" {
-		return true
-	}
-	// Specific SHA or otherwise not a "clean" version.
-	if semver.IsValid(v) && semver.Prerelease(v) != "" {
-		return true
+// checkProxy is a valid GOPROXY, a list of proxy URLs or direct or off.
+func checkProxy(proxy string) error {
+	for _, p := range strings.FieldsFunc(proxy, func(r rune) bool { return r == ',' || r == '|' }) {
+		if p == "direct" || p == "off" {
+			continue
+		}
+		u, err := url.Parse(p)
+		if err != nil || u.Host == "" && u.Scheme != "file" {
+			return fmt.Errorf("-proxy: %q is not a URL, direct or off", p)
+		}
+		switch u.Scheme {
+		case "https", "http", "file":
+		default:
+			return fmt.Errorf("-proxy: %q is not a URL, direct or off", p)
+		}
 	}
-	return false
+	return nil
 }
 
-// latest version of package, or error.
-func latest(ctx context.Context, pkg string) (string, error) {
-	cmd := exec.CommandContext(ctx, "go", "list", "-m", "-json", pkg+"@latest")
-	out, err := cmd.CombinedOutput()
+// checkDir is a directory of programs, named as what in errors, and
+// writable if need be, returning its absolute path.
+func checkDir(what, dir string, writable bool) (string, error) {
+	dir, err := filepath.Abs(dir)
 	if err != nil {
-		return "", fmt.Errorf("go list (%w):\n%s", err, out)
+		return "", fmt.Errorf("%s: %w", what, err)
 	}
-	listing := struct {
-		Version string
-	}{}
-	err = json.Unmarshal(out, &listing)
+	fi, err := os.Stat(dir)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return "", fmt.Errorf("%s directory does not exist: %s", what, dir)
+	case err != nil:
+		return "", fmt.Errorf("%s: %w", what, err)
+	case !fi.IsDir():
+		return "", fmt.Errorf("%s is not a directory: %s", what, dir)
+	case !writable:
+		return dir, nil
+	}
+	f, err := os.CreateTemp(dir, ".go-latest-")
 	if err != nil {
-		return "", fmt.Errorf("json unmarshal: %v", err)
+		return "", fmt.Errorf("%s is not writable: %w", what, err)
 	}
-	return listing.Version, nil
+	f.Close()
+	os.Remove(f.Name())
+	return dir, nil
 }
 
-func installer(ctx context.Context, nProcs int, latestGo, force bool) error {
-	dir := gobin()
-	if dir == "" {
-		return errors.New("GOBIN not found")
+// summarize results in a single record, returning the number of failures.
+// With quiet, there is no record unless something changed or failed.
+func summarize(log *slog.Logger, results []golatest.Result, took time.Duration, quiet bool) int {
+	count := map[string]int{}
+	for _, r := range results {
+		count[r.Action]++
 	}
-	progs, err := listPrograms(dir)
-	if err != nil {
-		return err
+	failed := count[golatest.ActionError] + count[golatest.ActionConflict]
+	// Zero counts only add noise.
+	var attrs []any
+	for _, c := range []struct {
+		key    string
+		action string
+	}{
+		{"upgraded", golatest.ActionUpgrade},
+		{"reinstalled", golatest.ActionReinstall},
+		{"installed", golatest.ActionInstall},
+		{"already_latest", golatest.ActionLatest},
+		{"skipped", golatest.ActionSkip},
+		{"declined", golatest.ActionDeclined},
+		{"held", golatest.ActionHeld},
+		{"orphaned", golatest.ActionOrphaned},
+		{"removed", golatest.ActionRemoved},
+		{"planned", golatest.ActionPlanned},
+	} {
+		if count[c.action] > 0 {
+			attrs = append(attrs, c.key, count[c.action])
+		}
 	}
-
-	var goVersion string
-	// Check against the local toolchain version of Go since that is
-	// what we're going to use to install programs.
-	if latestGo {
-		goVersion, err = goversion(ctx)
-		if err != nil {
-			return fmt.Errorf("go version: %w", err)
+	if failed > 0 {
+		attrs = append(attrs, "failed", failed)
+	}
+	// Lookups or installs never done, e.g. when interrupted.
+	if count[""] > 0 {
+		attrs = append(attrs, "cut_off", count[""])
+	}
+	var size int64
+	installed := false
+	for _, r := range results {
+		if r.SizeAfter > 0 {
+			size += r.SizeAfter - r.SizeBefore
+			installed = true
 		}
 	}
+	if installed {
+		attrs = append(attrs, "size", humanBytes(size))
+	}
+	if fixed := fixedVulns(results); len(fixed) > 0 {
+		attrs = append(attrs, "fixed", strings.Join(fixed, ","))
+	}
+	attrs = append(attrs, "duration", took.Round(time.Millisecond))
+	if !quiet || len(notable(results)) > 0 {
+		log.Info("summary", attrs...)
+	}
+	return failed
+}
 
-	var eg errgroup.Group
-	eg.SetLimit(nProcs)
-
-	for _, f := range progs {
-		ff := f
-		eg.Go(func() error {
-			info, err := buildinfo.ReadFile(ff)
-			if err != nil {
-				return err
-			}
-			if isSpecific(info.Main.Version) {
-				fmt.Printf("%s %s skip\n", info.Path, info.Main.Version)
-				return nil
-			}
+// maxLogSize of a -log file before warning about it.
+const maxLogSize = 64 << 20
 
-			// Latest available is checked per module.
-			// TODO: Cache this lookup.
-			target, err := latest(ctx, info.Main.Path)
-			if err != nil {
-				if errors.Is(err, context.Canceled) {
-					return nil
-				}
-				fmt.Printf("%s\n", err)
-				// TODO: Doesn't work for golang.org/x/tools/cmd/auth/authtest
-				target = "?"
-			}
+// maxWorkers by default, more parallel go installs mostly contend
+// for the module cache.
+const maxWorkers = 8
 
-			goUpgrade := latestGo && goVersion != info.GoVersion
-			modUpgrade := target != info.Main.Version
-			if !(force || goUpgrade || modUpgrade) {
-				fmt.Printf("%s %s already latest\n", info.Path, info.Main.Version)
-				return nil
-			}
-			fmt.Printf("%s %s -> %s\n", info.Path, info.Main.Version, target)
-
-			// TODO: Is it faster to combine packages from the same module into a single exec?
-			cmd := exec.CommandContext(ctx, "go", "install", info.Path+"@latest")
-			out, err := cmd.CombinedOutput()
-			if err != nil {
-				return fmt.Errorf("go install (%s):\n%s", err, out)
-			}
-			// TODO: If no longer present in module or deprecated, ask if remove?
-			return nil
-		})
+// lookupsPerWorker by default, lookups mostly wait on the network.
+const lookupsPerWorker = 4
 
+// workers installing and looking up at once for -j and -j-net, by default
+// as many installing as there are CPUs up to maxWorkers, and
+// lookupsPerWorker times that looking up.
+func workers(procs, lookups int) (int, int, error) {
+	if procs < 0 {
+		return 0, 0, fmt.Errorf("-workers must not be negative, got %d", procs)
 	}
-
-	return eg.Wait()
+	if procs == 0 {
+		procs = min(runtime.NumCPU(), maxWorkers)
+	}
+	if lookups < 0 {
+		return 0, 0, fmt.Errorf("-j-net must not be negative, got %d", lookups)
+	}
+	if lookups == 0 {
+		lookups = lookupsPerWorker * procs
+	}
+	return procs, lookups, nil
 }
 
-const help = `Usage: go-latest [options]
+const help = `Usage: go-latest [options] [program ...]
+       go-latest apply -plan file [options]
+       go-latest completion bash|zsh|fish
+       go-latest self-update
+       go-latest list [-json]
+       go-latest doctor
+       go-latest watch [-interval duration] [-auto] [options]
+       go-latest cache clear
 
-Install the latest version of go install'd programs in GOBIN.
+Install the latest version of go install'd programs in GOBIN, or of those
+named alone, e.g. gopls.
+With apply, install the versions planned by -dry-run -plan instead.
+With completion, print a completion script for the shell.
+With self-update, upgrade go-latest itself.
+With list, print the installed programs and versions, looking nothing up.
+With doctor, check for programs shadowed on PATH by others of the same name.
+With watch, check every -interval until stopped, printing the upgrades
+found, or installing them with -auto, and notifying of them with -notify.
+With cache clear, forget the latest versions looked up before.
 
 Options:
 `
 
-func runMain(ctx context.Context) error {
-	flag.Usage = func() {
-		fmt.Fprintf(flag.CommandLine.Output(), help)
-		flag.PrintDefaults()
+const exitHelp = `
+Exit status is 0 if nothing failed, 1 if looking up or installing any
+program failed, or something else did, 2 for invalid arguments, 124
+when cut off by -timeout and 130 when interrupted.
+`
+
+// runMain with arguments args parsed into flags, input from stdin and output
+// to stdout and stderr, running go commands with runner, exec if nil.
+func runMain(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer, flags *flag.FlagSet, runner golatest.Runner) error {
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), help)
+		flags.PrintDefaults()
+		fmt.Fprintf(flags.Output(), exitHelp)
+	}
+	showVersion := flags.Bool("v", false, "Print version and exit")
+	var nProcs int
+	flags.IntVar(&nProcs, "j", 0, "Number of parallel installs, shorthand for -workers")
+	flags.IntVar(&nProcs, "j-build", 0, "Number of parallel installs, same as -j")
+	flags.IntVar(&nProcs, "workers", 0, fmt.Sprintf("Number of parallel installs, defaults to number of CPUs up to %d", maxWorkers))
+	nLookups := flags.Int("j-net", 0, fmt.Sprintf("Number of parallel lookups, defaults to %d times -j", lookupsPerWorker))
+	flags.IntVar(&nProcs, "build-workers", 0, "Number of parallel installs, same as -j")
+	flags.IntVar(nLookups, "net-workers", 0, "Number of parallel lookups, same as -j-net")
+	latestGo := flags.Bool("go", false, "Re-install programs not built with the current version of Go, or that of -go-version")
+	toolchainOnly := flags.Bool("toolchain-only", false, "Re-install programs not built with the current version of Go at the version they are at, like -go but without upgrading")
+	goVersion := flags.String("go-version", "", "Install with Go toolchain `version`, e.g. go1.22.0, through GOTOOLCHAIN, implies -go")
+	force := flags.Bool("force", false, "Re-install everything")
+	pre := flags.Bool("pre", false, "Upgrade to the latest version including prereleases")
+	patchOnly := flags.Bool("patch-only", false, "Upgrade only to patch releases of the minor version installed")
+	sameMajor := flags.Bool("same-major", false, "Upgrade only to releases of the major version installed")
+	allowDowngrade := flags.Bool("allow-downgrade", false, "Install the latest version even when it's older than the installed one")
+	forceAll := flags.Bool("force-all", false, "Re-install everything, including programs at specific versions")
+	jsonOut := flags.Bool("json", false, "Print results as JSON when done, moving the log to stderr")
+	jsonStreamOut := flags.Bool("json-stream", false, "Print events as JSON lines as they happen, moving the log to stderr:\nscan-started, binary-resolved, install-started, install-finished and run-finished")
+	logFormat := flags.String("log-format", "text", "Output format, text or json")
+	colorMode := flags.String("color", "auto", "Color output, auto, always or never")
+	logLevel := flags.String("log-level", "info", "Minimum level to output, debug, info, warn or error")
+	var interactive bool
+	flags.BoolVar(&interactive, "i", false, "Ask before each upgrade, shorthand for -interactive")
+	flags.BoolVar(&interactive, "interactive", false, "Ask before each upgrade")
+	useTUI := flags.Bool("tui", false, "Pick which upgrades to install from a list in the terminal, or ask before each like -interactive when output is not one")
+	removeOrphaned := flags.Bool("remove-orphaned", false, "Remove programs whose package no longer exists in the latest version of its module")
+	var gobin string
+	flags.StringVar(&gobin, "gobin", "", "Upgrade the programs in `dir`, and install to it, rather than GOBIN, creating it if need be")
+	flags.StringVar(&gobin, "bin", "", "Upgrade the programs in `dir`, same as -gobin")
+	syncFile := flags.String("sync", "", "Also install tools from manifest `file` which are missing from GOBIN")
+	wide := flags.Bool("wide", false, "Don't shorten long package paths in the text output")
+	cacheTTL := flags.Duration("cache-ttl", time.Hour, "Reuse latest versions looked up within `duration`")
+	noCache := flags.Bool("no-cache", false, "Look up every latest version, neither reading nor writing the cache")
+	lookupTimeout := flags.Duration("lookup-timeout", golatest.DefaultLookupTimeout, "Give up on each go list looking up versions after `duration`, trying again twice")
+	timeout := flags.Duration("timeout", 0, "Stop after `duration`, cutting off lookups and installs in flight, e.g. 10m")
+	maxAge := flags.Duration("max-age", 0, "Only upgrade programs installed longer ago than `duration`, e.g. 168h")
+	perHost := flags.Int("per-host", 0, "Limit the go commands fetching from any one host at a time, unlimited by default")
+	perModule := flags.Int("per-module", 0, "Limit the upgrades installing from any one module at a time, unlimited by default")
+	maxQPS := flags.Float64("max-qps", 0, "Limit the go commands querying the module proxy to `n` a second, unlimited by default")
+	vuln := flags.Bool("vuln", false, "Look up known vulnerabilities of the modules programs were built with")
+	onlyVulnerable := flags.Bool("only-vulnerable", false, "Upgrade only programs with vulnerabilities the upgrade fixes, implies -vuln")
+	vulnDB := flags.String("vuln-db", golatest.DefaultVulnDB, "OSV API `URL` to look up vulnerabilities in, with -vuln")
+	changelog := flags.Bool("changelog", false, "List the versions released between those installed and the latest of upgrades")
+	noLinks := flags.Bool("no-links", false, "Don't link to the changes of upgraded programs")
+	traceCmds := flags.Bool("x", false, "Print the go commands as they are run, to stderr")
+	parallelOutput := flags.String("parallel-output", "buffer", "Output of go install, buffer to only show it when failing, or stream to also print it to stderr\nas it's written, each line after the name of the program, e.g. [gopls]")
+	ignoreSettings := flags.Bool("ignore-settings", false, "Reinstall with default build flags and environment, rather than those of the original build")
+	proxy := flags.String("proxy", "", "Module proxy `URL` to use instead of GOPROXY, or direct, or off for only the module cache")
+	private := flags.String("private", "", "Comma separated module path `patterns` to fetch directly and skip checksums for, added to GOPRIVATE")
+	offline := flags.Bool("offline", false, "Look up and install only what is in the module cache, like GOPROXY=off")
+	var quietOut bool
+	flags.BoolVar(&quietOut, "q", false, "Only print what changed or failed, shorthand for -quiet")
+	flags.BoolVar(&quietOut, "quiet", false, "Only print what changed or failed")
+	onlyOutdated := flags.Bool("only-outdated", false, "Only print programs behind the latest version, or that of Go with -go, counting the rest in the summary")
+	dryRun := flags.Bool("dry-run", false, "Look up the latest versions but install nothing")
+	timings := flags.Bool("timings", false, "Show how long looking up and installing each program took, and which were slowest")
+	logPath := flags.String("log", "", "Append a JSON record of each program and the summary to `file`")
+	planFile := flags.String("plan", "", "Write the upgrades of a -dry-run to `file`, or install those in it with apply")
+	format := flags.String("format", "", "Print each program with Go `template`, e.g. '{{.Name}} {{.Installed}} {{.Target}}', fields:\nName, Path, Module, Installed, Target, Action, Error, GoVersion and Duration,\nor as csv with a header row, or a markdown table")
+	outPath := flags.String("o", "", "Write the table, -format or -json output to `file` rather than stdout")
+	group := flags.Bool("group", false, "Print what changed, then what failed in full, then a count of the rest")
+	sortBy := flags.String("sort", "path", "Order of the programs printed, path, status or duration")
+	stream := flags.Bool("stream", false, "Print each program as soon as it's done, rather than a table at the end")
+	interval := flags.Duration("interval", 24*time.Hour, "Check for upgrades every `duration` with watch")
+	auto := flags.Bool("auto", false, "Install the upgrades found with watch, rather than only print them")
+	notify := flags.Bool("notify", false, "Notify of the upgrades found with watch on the desktop, with notify-send or osascript")
+	notifyURL := flags.String("notify-url", "", "POST the upgrades found with watch as JSON to `URL`, rather than notify on the desktop, implies -notify")
+	if len(args) > 0 && args[0] == "completion" {
+		if len(args) != 2 {
+			return usageError{errors.New("usage: go-latest completion bash|zsh|fish")}
+		}
+		return completion(stdout, args[1], flags)
+	}
+	if len(args) > 0 && args[0] == "self-update" {
+		if len(args) != 1 {
+			return usageError{errors.New("usage: go-latest self-update")}
+		}
+		return selfUpdate(ctx, stdout, runner)
+	}
+	if len(args) > 0 && args[0] == "cache" {
+		if len(args) != 2 || args[1] != "clear" {
+			return usageError{errors.New("usage: go-latest cache clear")}
+		}
+		err := os.Remove(golatest.DefaultCacheFile())
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	apply := len(args) > 0 && args[0] == "apply"
+	listing := len(args) > 0 && args[0] == "list"
+	doctoring := len(args) > 0 && args[0] == "doctor"
+	watching := len(args) > 0 && args[0] == "watch"
+	// Hidden, for completion scripts to complete program names with.
+	completing := len(args) > 0 && args[0] == "__programs"
+	if apply || listing || doctoring || watching || completing {
+		args = args[1:]
+	}
+	err := flags.Parse(args)
+	if errors.Is(err, flag.ErrHelp) {
+		return nil
+	}
+	if err != nil {
+		return usageError{err}
+	}
+	if flags.NArg() > 0 && (apply || listing || doctoring || watching || completing) {
+		flags.Usage()
+		return usageError{fmt.Errorf("unexpected arguments: %s", strings.Join(flags.Args(), " "))}
 	}
-	showVersion := flag.Bool("v", false, "Print version and exit")
-	nProcs := flag.Int("j", 0, "Number of parallel workers, defaults to number of CPUs")
-	latestGo := flag.Bool("go", false, "Re-install programs not built with the current version of Go")
-	force := flag.Bool("force", false, "Re-install everything")
-	flag.Parse()
 
 	if *showVersion {
 		bi, ok := debug.ReadBuildInfo()
 		if !ok {
 			return errors.New("could not read buildinfo")
 		}
-		fmt.Println(bi.Main.Version)
+		fmt.Fprintln(stdout, bi.Main.Version)
 		return nil
 	}
-	if *nProcs == 0 {
-		*nProcs = runtime.NumCPU()
+	dir := golatest.GOBIN()
+	switch {
+	case gobin != "":
+		// E.g. a new ./bin of a project to -sync tools to.
+		err = os.MkdirAll(gobin, 0o755)
+		if err == nil {
+			dir, err = checkDir("-gobin", gobin, true)
+		}
+	case dir != "":
+		dir, err = checkDir("GOBIN", dir, false)
+	}
+	if err != nil {
+		return usageError{err}
+	}
+	if listing {
+		return list(stdout, *jsonOut, dir)
+	}
+	if completing {
+		return programNames(stdout, dir)
+	}
+	if doctoring {
+		return doctor(stdout, binDirs(ctx, runner, dir), os.Getenv("PATH"))
+	}
+	var tmpl *template.Template
+	if *format != "" && *format != "csv" && *format != "markdown" {
+		var err error
+		tmpl, err = template.New("format").Parse(*format)
+		if err != nil {
+			return usageError{fmt.Errorf("-format: %w", err)}
+		}
+	}
+	if !slices.Contains(flagValues["sort"], *sortBy) {
+		return usageError{fmt.Errorf("-sort: %q is not path, status or duration", *sortBy)}
+	}
+	if !slices.Contains(flagValues["parallel-output"], *parallelOutput) {
+		return usageError{fmt.Errorf("-parallel-output: %q is not buffer or stream", *parallelOutput)}
+	}
+	nProcs, *nLookups, err = workers(nProcs, *nLookups)
+	if err != nil {
+		return usageError{err}
+	}
+	var runLog *os.File
+	if *logPath != "" {
+		// Before doing anything, lest it fail at the end.
+		var err error
+		runLog, err = os.OpenFile(*logPath, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+		if err != nil {
+			return fmt.Errorf("log: %w", err)
+		}
+		defer runLog.Close()
+	}
+	var outFile *os.File
+	if *outPath != "" {
+		var err error
+		outFile, err = os.Create(*outPath)
+		if err != nil {
+			return fmt.Errorf("-o: %w", err)
+		}
+		defer outFile.Close()
+	}
+	logFile := stdout
+	if *jsonOut && *jsonStreamOut {
+		return usageError{errors.New("-json and -json-stream are mutually exclusive")}
+	}
+	if *jsonOut || *jsonStreamOut {
+		logFile = stderr
+	}
+	color, err := useColor(*colorMode, logFile)
+	if err != nil {
+		return usageError{err}
+	}
+	var logOut io.Writer = logFile
+	var ui *tui
+	var hold *holdWriter
+	if *useTUI && interactive {
+		return usageError{errors.New("-tui and -interactive are mutually exclusive")}
+	}
+	if watching {
+		if *useTUI || interactive || *planFile != "" {
+			return usageError{errors.New("watch with -tui, -interactive or -plan makes no sense")}
+		}
+		if *interval <= 0 {
+			return usageError{fmt.Errorf("-interval must be positive, got %s", *interval)}
+		}
+		if !*auto {
+			*dryRun = true
+		}
+	} else if *notify || *notifyURL != "" {
+		return usageError{errors.New("-notify and -notify-url require watch")}
+	}
+	var notes *notifier
+	if *notify || *notifyURL != "" {
+		notes = &notifier{url: *notifyURL, client: http.DefaultClient}
+	}
+	if *useTUI && !isTerminal(stdin) {
+		// Nothing to pick with, nor answer questions.
+		return usageError{errors.New("-tui requires stdin to be a terminal")}
+	}
+	if *useTUI && !isTerminal(stdout) {
+		// No list to draw with output going elsewhere, ask instead.
+		*useTUI, interactive = false, true
+	}
+	if *useTUI {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithCancel(ctx)
+		defer cancel()
+		ui, err = newTUI(stdin, stdout, cancel)
+		if err != nil {
+			return err
+		}
+		// Hold the log until the terminal is back to normal.
+		hold = newHoldWriter(logOut)
+		logOut = hold
+		defer func() {
+			ui.close()
+			hold.release()
+		}()
+	}
+	var prog *progress
+	// Only for someone watching the output, which is not JSON.
+	if !interactive && ui == nil && isTerminal(stdout) && isTerminal(stderr) && !*jsonOut && !*jsonStreamOut {
+		prog = newProgress(stderr)
+		logOut = prog.writer(logOut)
+	}
+	var trace io.Writer
+	if *traceCmds {
+		trace = stderr
+		switch {
+		case ui != nil:
+			traceHold := newHoldWriter(stderr)
+			trace = traceHold
+			defer traceHold.release()
+		case prog != nil:
+			trace = prog.writer(stderr)
+		}
+	}
+	var output io.Writer
+	if *parallelOutput == "stream" {
+		output = stderr
+		switch {
+		case ui != nil:
+			outputHold := newHoldWriter(stderr)
+			output = outputHold
+			defer outputHold.release()
+		case prog != nil:
+			output = prog.writer(stderr)
+		}
+	}
+	log, err := newLogger(logOut, *logFormat, *logLevel, color)
+	if err != nil {
+		return usageError{err}
+	}
+	var runLogger *slog.Logger
+	if runLog != nil {
+		runLogger = slog.New(slog.NewJSONHandler(runLog, nil))
+		if fi, err := runLog.Stat(); err == nil && fi.Size() > maxLogSize {
+			log.Warn(fmt.Sprintf("log %s is over %d MiB, consider rotating it", *logPath, maxLogSize>>20))
+		}
+	}
+
+	opts := golatest.Options{
+		Dir:            dir,
+		Programs:       flags.Args(),
+		Workers:        nProcs,
+		LookupWorkers:  *nLookups,
+		LookupTimeout:  *lookupTimeout,
+		LatestGo:       *latestGo,
+		ToolchainOnly:  *toolchainOnly,
+		Force:          *force || *forceAll,
+		ForceAll:       *forceAll,
+		Pre:            *pre,
+		AllowDowngrade: *allowDowngrade,
+		PatchOnly:      *patchOnly,
+		SameMajor:      *sameMajor,
+		Changelog:      *changelog,
+		RemoveOrphaned: *removeOrphaned,
+		Vulns:          *vuln,
+		VulnDB:         *vulnDB,
+		OnlyVulnerable: *onlyVulnerable,
+		PerHost:        *perHost,
+		PerModule:      *perModule,
+		MaxQPS:         *maxQPS,
+		MaxAge:         *maxAge,
+		DryRun:         *dryRun,
+		IgnoreSettings: *ignoreSettings,
+		Runner:         runner,
+		Trace:          trace,
+		Output:         output,
+		Log:            log,
+	}
+	if *syncFile != "" {
+		opts.Sync, err = golatest.ReadManifest(*syncFile)
+		if err != nil {
+			return err
+		}
+	}
+	switch {
+	case apply:
+		if *planFile == "" {
+			return usageError{errors.New("apply requires -plan")}
+		}
+		if *dryRun || opts.Sync != nil {
+			return usageError{errors.New("apply with -dry-run or -sync makes no sense")}
+		}
+		opts.Plan, err = golatest.ReadPlan(*planFile)
+		if err != nil {
+			return err
+		}
+		// Everything in the plan was an upgrade when planned.
+		opts.Force = true
+	case *planFile != "" && !*dryRun:
+		return usageError{errors.New("-plan requires -dry-run, or apply")}
+	}
+	if interactive {
+		if !isTerminal(stdin) {
+			return usageError{errors.New("-interactive requires stdin to be a terminal")}
+		}
+		opts.Confirm = newPrompter(stdin, stderr).confirm
+	}
+	var sinks []func(golatest.Event)
+	if ui != nil {
+		opts.Choose = ui.choose
+		sinks = append(sinks, ui.event)
+	}
+	if prog != nil {
+		sinks = append(sinks, prog.event)
+	}
+	hide := func(r golatest.Result) bool {
+		return quietOut && isNoise(r) || *onlyOutdated && !isOutdated(r)
+	}
+	var st *streamer
+	if *stream {
+		st = newStreamer(log, *timings, hide)
+		sinks = append(sinks, st.event)
+	}
+	var jsonl *jsonStream
+	if *jsonStreamOut {
+		jsonl = newJSONStream(stdout)
+		sinks = append(sinks, jsonl.event)
+	}
+	var runSt *streamer
+	if runLogger != nil {
+		// Written as it happens, in case the run never gets to the end.
+		runSt = newStreamer(runLogger, true, nil)
+		sinks = append(sinks, runSt.event)
+	}
+	if len(sinks) > 0 {
+		opts.Events = func(e golatest.Event) {
+			if *noLinks {
+				e.Result.Changes = ""
+			}
+			for _, sink := range sinks {
+				sink(e)
+			}
+		}
+	}
+
+	env, err := golatest.GoEnv(ctx, runner, trace, "GOPATH", "GOMODCACHE", "GOPROXY", "GOPRIVATE")
+	if err != nil {
+		return err
+	}
+	// Pin these for all go commands, wherever they run, so that what
+	// go list downloads while resolving go install finds in the cache.
+	for _, k := range []string{"GOPATH", "GOMODCACHE"} {
+		if env[k] != "" {
+			opts.Env = append(opts.Env, k+"="+env[k])
+		}
+	}
+	if *private != "" {
+		// In addition to what is already private, which GONOPROXY and
+		// GONOSUMDB default to as well.
+		patterns := *private
+		if env["GOPRIVATE"] != "" {
+			patterns += "," + env["GOPRIVATE"]
+		}
+		opts.Env = append(opts.Env, "GOPRIVATE="+patterns)
+	}
+	if *goVersion != "" {
+		if !strings.HasPrefix(*goVersion, "go1") {
+			return usageError{fmt.Errorf("-go-version: %q is not a Go version like go1.22.0", *goVersion)}
+		}
+		// Which go env GOVERSION then reports, for -go to compare with.
+		opts.Env = append(opts.Env, "GOTOOLCHAIN="+*goVersion)
+		opts.LatestGo = true
+	}
+	if *proxy != "" {
+		err = checkProxy(*proxy)
+		if err != nil {
+			return usageError{err}
+		}
+		opts.Env = append(opts.Env, "GOPROXY="+*proxy)
+		env["GOPROXY"] = *proxy
+	}
+	if gobin != "" {
+		// Where go install puts them.
+		opts.Env = append(opts.Env, "GOBIN="+dir)
+	}
+	if env["GOPROXY"] == "off" || *offline {
+		opts.Offline = true
+		reason := "-offline"
+		if env["GOPROXY"] == "off" {
+			reason = "GOPROXY=off"
+		}
+		log.Warn(fmt.Sprintf("module proxy unavailable (%s), only checking for updates in the module cache", reason))
 	}
 
-	dir, err := os.MkdirTemp("", "")
+	tmp, err := os.MkdirTemp("", "")
 	if err != nil {
 		return fmt.Errorf("make temp dir: %w", err)
 	}
-	defer os.Remove(dir)
-	err = os.Chdir(dir)
+	defer os.Remove(tmp)
+	err = os.Chdir(tmp)
 	if err != nil {
 		return fmt.Errorf("chdir: %w", err)
 	}
 
-	err = installer(ctx, *nProcs, *latestGo, *force)
-	if err != nil {
-		return err
+	if !*noCache && *cacheTTL > 0 && golatest.DefaultCacheFile() != "" {
+		opts.Cache, err = golatest.OpenCache(golatest.DefaultCacheFile(), *cacheTTL)
+		if err != nil {
+			return fmt.Errorf("cache: %w", err)
+		}
 	}
-	return nil
+
+	// run once, or every -interval with watch.
+	run := func(ctx context.Context) error {
+		if *timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, *timeout)
+			defer cancel()
+		}
+		start := time.Now()
+		results, err := golatest.New(opts).Upgrade(ctx)
+		if *noLinks {
+			for i := range results {
+				results[i].Changes = ""
+			}
+		}
+		if opts.Cache != nil {
+			if err := opts.Cache.Save(); err != nil {
+				log.Warn("cache not saved", "err", err)
+			}
+		}
+		if prog != nil {
+			prog.stop()
+		}
+		if ui != nil {
+			ui.close()
+			hold.release()
+		}
+		if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return timeoutError{fmt.Errorf("timed out after %s: %w", *timeout, err)}
+		}
+		if err != nil {
+			return err
+		}
+		took := time.Since(start)
+		sortResults(results, *sortBy)
+		var shown []golatest.Result
+		for _, r := range results {
+			if !hide(r) {
+				shown = append(shown, r)
+			}
+		}
+		out, outColor := logOut, color
+		if outFile != nil {
+			out, outColor = outFile, false
+		}
+		switch {
+		case st != nil:
+			st.flush(results)
+		case *format == "csv":
+			err = csvResults(out, shown)
+			if err != nil {
+				return err
+			}
+		case *format == "markdown":
+			err = markdownResults(out, shown, dir, start)
+			if err != nil {
+				return err
+			}
+		case tmpl != nil:
+			err = formatResults(out, log, tmpl, shown)
+			if err != nil {
+				return err
+			}
+		case *logFormat == "text" && *group:
+			err = grouped(out, shown, outColor, *wide, *timings)
+			if err != nil {
+				return err
+			}
+		case *logFormat == "text":
+			err = table(out, shown, outColor, *wide, *timings)
+			if err != nil {
+				return err
+			}
+		default:
+			report(log, shown, *timings)
+		}
+		if *timings && (st != nil || *logFormat != "text") {
+			reportSlowest(log, results, 5)
+		}
+		if *dryRun && *planFile != "" {
+			err = golatest.WritePlan(*planFile, results)
+			if err != nil {
+				return fmt.Errorf("plan: %w", err)
+			}
+		}
+		failed := summarize(log, results, took, quietOut)
+		if notes != nil {
+			notes.notify(ctx, log, results)
+		}
+		if jsonl != nil {
+			jsonl.finished(results, took)
+		}
+		if runSt != nil {
+			runSt.flush(results)
+			summarize(runLogger, results, took, false)
+		}
+		if *jsonOut {
+			w := stdout
+			if outFile != nil {
+				w = outFile
+			}
+			err = json.NewEncoder(w).Encode(struct {
+				Results    []golatest.Result `json:"results"`
+				DurationMS int64             `json:"duration_ms"`
+			}{results, took.Milliseconds()})
+			if err != nil {
+				return err
+			}
+		}
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			cut := 0
+			for _, r := range results {
+				if r.Action == "" {
+					cut++
+				}
+			}
+			return timeoutError{fmt.Errorf("timed out after %s, %d of %d programs cut off", *timeout, cut, len(results))}
+		}
+		if failed > 0 {
+			return fmt.Errorf("%d of %d programs failed", failed, len(results))
+		}
+		return nil
+	}
+	if watching {
+		return watch(ctx, log, *interval, run)
+	}
+	return run(ctx)
+}
+
+// watch calls run right away and then every interval, until ctx is done,
+// logging the failures of each run rather than stopping. Being stopped is
+// not a failure of its own.
+func watch(ctx context.Context, log *slog.Logger, interval time.Duration, run func(context.Context) error) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		err := run(ctx)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			log.Error("check failed", "err", err)
+		}
+		log.Info("watching for upgrades", "every", interval)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// interruptible context cancelled on the first interrupt, or SIGTERM, giving work in
+// flight a chance to wind down. A second interrupt exits right away.
+func interruptible() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		select {
+		case <-sigs:
+		case <-ctx.Done():
+			signal.Stop(sigs)
+			return
+		}
+		fmt.Fprintln(os.Stderr, "interrupted, stopping (interrupt again to quit now)")
+		cancel()
+		<-sigs
+		os.Exit(exitInterrupted)
+	}()
+	return ctx, cancel
 }
 
 func main() {
-	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	ctx, cancel := interruptible()
 	defer cancel()
 
-	err := runMain(ctx)
-	if err != nil {
-		if !errors.Is(err, context.Canceled) {
-			fmt.Printf("%s", err.Error())
-		}
-		os.Exit(1)
+	flags := flag.NewFlagSet("go-latest", flag.ContinueOnError)
+	err := runMain(ctx, os.Args[1:], os.Stdin, os.Stdout, os.Stderr, flags, nil)
+	if err != nil && !errors.Is(err, context.Canceled) {
+		fmt.Fprintln(os.Stderr, err)
 	}
+	os.Exit(exitStatus(err))
 }
@@ -0,0 +1,406 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/vikblom/go-latest/golatest"
+)
+
+// fakeGo runs go commands by calling install for go install and
+// answering go env with nothing set, anything else failing.
+type fakeGo struct {
+	install func(ctx context.Context, args ...string) error
+	envErr  error
+}
+
+func (f fakeGo) Run(ctx context.Context, _ []string, args ...string) ([]byte, []byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+	switch args[0] {
+	case "env":
+		if f.envErr != nil {
+			return nil, []byte(f.envErr.Error()), f.envErr
+		}
+		return []byte("{}"), nil, nil
+	case "install":
+		if f.install != nil {
+			return nil, nil, f.install(ctx, args...)
+		}
+	}
+	return nil, []byte("unexpected"), errors.New("exit status 1")
+}
+
+// run runMain with args in an empty GOBIN of its own, returning the exit
+// status and output.
+func run(t *testing.T, ctx context.Context, runner golatest.Runner, args ...string) (int, string) {
+	t.Helper()
+	// runMain runs in a temp dir of its own.
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	dir := t.TempDir()
+	args = append([]string{"-gobin", filepath.Join(dir, "bin"), "-no-cache"}, args...)
+	var out bytes.Buffer
+	flags := flag.NewFlagSet("go-latest", flag.ContinueOnError)
+	err = runMain(ctx, args, strings.NewReader(""), &out, &out, flags, runner)
+	return exitStatus(err), out.String()
+}
+
+// manifest file of a single tool, to install with -sync.
+func manifest(t *testing.T) string {
+	t.Helper()
+	f := filepath.Join(t.TempDir(), "tools.json")
+	err := os.WriteFile(f, []byte(`{"tools": [{"path": "example.com/tool", "version": "v1.0.0"}]}`), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+func TestExitStatus(t *testing.T) {
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	failInstall := func(context.Context, ...string) error { return errors.New("exit status 1") }
+	okInstall := func(context.Context, ...string) error { return nil }
+	hang := func(ctx context.Context, _ ...string) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	for _, tt := range []struct {
+		name   string
+		ctx    context.Context
+		runner fakeGo
+		args   []string
+		want   int
+	}{
+		{name: "nothing to do", want: 0},
+		{name: "installed", runner: fakeGo{install: okInstall}, args: []string{"-sync", manifest(t)}, want: 0},
+		{name: "install failed", runner: fakeGo{install: failInstall}, args: []string{"-sync", manifest(t)}, want: exitFailed},
+		{name: "go env failed", runner: fakeGo{envErr: errors.New("exit status 1")}, want: exitFailed},
+		{name: "unknown flag", args: []string{"-no-such-flag"}, want: exitUsage},
+		{name: "no such program", args: []string{"gopls"}, want: exitFailed},
+		{name: "negative workers", args: []string{"-workers", "-1"}, want: exitUsage},
+		{name: "negative -j", args: []string{"-j", "-5"}, want: exitUsage},
+		{name: "all CPUs", runner: fakeGo{install: okInstall}, args: []string{"-sync", manifest(t), "-j", "0"}, want: 0},
+		{name: "bad sort", args: []string{"-sort", "size"}, want: exitUsage},
+		{name: "help", args: []string{"-h"}, want: 0},
+		{name: "timeout", runner: fakeGo{install: hang}, args: []string{"-sync", manifest(t), "-timeout", "10ms"}, want: exitTimeout},
+		{name: "interrupted", ctx: canceled, want: exitInterrupted},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := tt.ctx
+			if ctx == nil {
+				ctx = context.Background()
+			}
+			got, out := run(t, ctx, tt.runner, tt.args...)
+			if got != tt.want {
+				t.Errorf("exit status %d, want %d, output:\n%s", got, tt.want, out)
+			}
+		})
+	}
+}
+
+func TestWorkers(t *testing.T) {
+	cpus := min(runtime.NumCPU(), maxWorkers)
+	for _, tt := range []struct {
+		name           string
+		procs, lookups int
+		want, wantNet  int
+		err            string
+	}{
+		{"defaults", 0, 0, cpus, lookupsPerWorker * cpus, ""},
+		{"procs", 3, 0, 3, lookupsPerWorker * 3, ""},
+		// Not capped when asked for.
+		{"many", 2 * maxWorkers, 0, 2 * maxWorkers, lookupsPerWorker * 2 * maxWorkers, ""},
+		{"lookups", 0, 5, cpus, 5, ""},
+		{"both", 2, 5, 2, 5, ""},
+		{"negative", -5, 0, 0, 0, "-workers must not be negative, got -5"},
+		{"negative lookups", 0, -1, 0, 0, "-j-net must not be negative, got -1"},
+	} {
+		procs, lookups, err := workers(tt.procs, tt.lookups)
+		if tt.err != "" {
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("%s: %v, want %q", tt.name, err, tt.err)
+			}
+			continue
+		}
+		if err != nil || procs != tt.want || lookups != tt.wantNet {
+			t.Errorf("%s: workers(%d, %d) = %d, %d, %v, want %d, %d", tt.name, tt.procs, tt.lookups, procs, lookups, err, tt.want, tt.wantNet)
+		}
+	}
+}
+
+// envGo answers go env with env and records the env of every other go
+// command, each succeeding.
+type envGo struct {
+	env map[string]string
+
+	mu   sync.Mutex
+	envs [][]string
+}
+
+func (g *envGo) Run(_ context.Context, env []string, args ...string) ([]byte, []byte, error) {
+	if args[0] == "env" {
+		out, err := json.Marshal(g.env)
+		return out, nil, err
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.envs = append(g.envs, env)
+	return nil, nil, nil
+}
+
+func TestChildEnv(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		env  map[string]string
+		args []string
+		want map[string]string
+	}{
+		{"private", nil, []string{"-private", "corp.example.com/*"}, map[string]string{"GOPRIVATE": "corp.example.com/*"}},
+		{"more private", map[string]string{"GOPRIVATE": "old.example.com"}, []string{"-private", "corp.example.com/*"}, map[string]string{"GOPRIVATE": "corp.example.com/*,old.example.com"}},
+		{"proxy", map[string]string{"GOPROXY": "https://proxy.golang.org,direct"}, []string{"-proxy", "https://corp.example.com/proxy"}, map[string]string{"GOPROXY": "https://corp.example.com/proxy"}},
+		// The module cache, that is.
+		{"proxy off", map[string]string{"GOMODCACHE": "/go/pkg/mod"}, []string{"-proxy", "off"}, map[string]string{"GOPROXY": "file:///go/pkg/mod/cache/download", "GOSUMDB": "off"}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.name == "proxy off" && runtime.GOOS == "windows" {
+				t.Skip("module cache on a drive")
+			}
+			g := &envGo{env: tt.env}
+			status, out := run(t, context.Background(), g, append([]string{"-sync", manifest(t)}, tt.args...)...)
+			if status != 0 {
+				t.Fatalf("exit status %d, output:\n%s", status, out)
+			}
+			if len(g.envs) == 0 {
+				t.Fatalf("ran no go command but go env")
+			}
+			for _, env := range g.envs {
+				// The last of each, as exec.Cmd takes it.
+				got := map[string]string{}
+				for _, kv := range env {
+					k, v, _ := strings.Cut(kv, "=")
+					got[k] = v
+				}
+				for k, v := range tt.want {
+					if got[k] != v {
+						t.Errorf("go command with env %q, want %s=%s", env, k, v)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestCheckProxy(t *testing.T) {
+	for _, tt := range []struct {
+		proxy string
+		ok    bool
+	}{
+		{"", true},
+		{"https://proxy.golang.org,direct", true},
+		{"https://corp.example.com/proxy|https://proxy.golang.org|off", true},
+		{"http://localhost:3000", true},
+		{"file:///tmp/proxy", true},
+		{"proxy.golang.org", false},
+		{"ftp://proxy.example.com", false},
+		{"https://proxy.golang.org,directly", false},
+	} {
+		if err := checkProxy(tt.proxy); (err == nil) != tt.ok {
+			t.Errorf("checkProxy(%q) = %v, want ok %t", tt.proxy, err, tt.ok)
+		}
+	}
+}
+
+func TestCheckDir(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "file")
+	if err := os.WriteFile(file, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	readOnly := filepath.Join(dir, "ro")
+	if err := os.Mkdir(readOnly, 0o555); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		name     string
+		dir      string
+		writable bool
+		want     string
+	}{
+		{"dir", dir, true, ""},
+		{"missing", filepath.Join(dir, "missing"), false, "GOBIN directory does not exist"},
+		{"file", file, false, "GOBIN is not a directory"},
+		{"read only", readOnly, false, ""},
+		{"read only writable", readOnly, true, "GOBIN is not writable"},
+	} {
+		if tt.name == "read only writable" && (runtime.GOOS == "windows" || os.Geteuid() == 0) {
+			// Writable all the same.
+			continue
+		}
+		got, err := checkDir("GOBIN", tt.dir, tt.writable)
+		switch {
+		case tt.want == "" && err != nil:
+			t.Errorf("%s: %v", tt.name, err)
+		case tt.want == "" && !filepath.IsAbs(got):
+			t.Errorf("%s: got %s, want an absolute path", tt.name, got)
+		case tt.want != "" && (err == nil || !strings.Contains(err.Error(), tt.want)):
+			t.Errorf("%s: got error %v, want %q", tt.name, err, tt.want)
+		}
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 2 {
+		t.Errorf("left %d files behind in %s, want none", len(entries)-2, dir)
+	}
+}
+
+func TestWatch(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var logged bytes.Buffer
+	log := slog.New(slog.NewTextHandler(&logged, nil))
+	runs := 0
+	err := watch(ctx, log, time.Millisecond, func(ctx context.Context) error {
+		runs++
+		switch runs {
+		case 1:
+			return errors.New("go env failed")
+		case 3:
+			cancel()
+			return ctx.Err()
+		}
+		return nil
+	})
+	if err != nil {
+		t.Errorf("watch: %v, want nil once stopped", err)
+	}
+	if runs != 3 {
+		t.Errorf("ran %d times, want 3", runs)
+	}
+	if s := logged.String(); strings.Count(s, "check failed") != 1 || !strings.Contains(s, "go env failed") {
+		t.Errorf("logged %q, want the first run failing alone", s)
+	}
+}
+
+// failingGo fails every go install, after some lines of output naming the
+// program, as go env answers with nothing set.
+type failingGo struct{}
+
+func (failingGo) Run(_ context.Context, _ []string, args ...string) ([]byte, []byte, error) {
+	switch args[0] {
+	case "env":
+		return []byte("{}"), nil, nil
+	case "install":
+		pkg, _, _ := strings.Cut(args[len(args)-1], "@")
+		var out strings.Builder
+		for i := 0; i < 5; i++ {
+			fmt.Fprintf(&out, "%s line %d\n", path.Base(pkg), i)
+			// Long enough for the others to run meanwhile.
+			time.Sleep(time.Millisecond)
+		}
+		return nil, []byte(out.String()), errors.New("exit status 1")
+	}
+	return nil, []byte("unexpected"), errors.New("exit status 1")
+}
+
+func TestFailedOutput(t *testing.T) {
+	var tools []string
+	for i := 0; i < 16; i++ {
+		tools = append(tools, fmt.Sprintf(`{"path": "example.com/tool%d"}`, i))
+	}
+	m := filepath.Join(t.TempDir(), "tools.json")
+	err := os.WriteFile(m, []byte(`{"tools": [`+strings.Join(tools, ", ")+`]}`), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// As each finishes, then all at once grouped or in a table.
+	for _, mode := range []string{"-stream", "-group", "-wide"} {
+		t.Run(mode, func(t *testing.T) {
+			status, out := run(t, context.Background(), failingGo{}, "-sync", m, "-j", "8", mode)
+			if status != exitFailed {
+				t.Errorf("exit status %d, want %d", status, exitFailed)
+			}
+			// Each line of output of a program is in the block after
+			// its name.
+			var name string
+			blocks, lines := map[string]bool{}, 0
+			for _, l := range strings.Split(out, "\n") {
+				if n, ok := strings.CutPrefix(l, "=== "); ok {
+					name = strings.TrimSuffix(n, " ===")
+					if blocks[name] {
+						t.Errorf("output of %s in more than one block", name)
+					}
+					blocks[name] = true
+					continue
+				}
+				tool, _, ok := strings.Cut(l, " line ")
+				if !ok {
+					name = ""
+					continue
+				}
+				lines++
+				if tool != name {
+					t.Errorf("line %q in the block of %q", l, name)
+				}
+			}
+			if len(blocks) != len(tools) || lines != 5*len(tools) {
+				t.Errorf("%d blocks of %d lines, want %d of %d:\n%s", len(blocks), lines, len(tools), 5*len(tools), out)
+			}
+		})
+	}
+}
+
+func TestNotTerminal(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	install := func(context.Context, ...string) error {
+		t.Errorf("installed without asking")
+		return nil
+	}
+	// Input from a pipe or file, say.
+	for _, tt := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"-interactive"}, "-interactive requires stdin to be a terminal"},
+		{[]string{"-i"}, "-interactive requires stdin to be a terminal"},
+		{[]string{"-tui"}, "-tui requires stdin to be a terminal"},
+	} {
+		var b strings.Builder
+		flags := flag.NewFlagSet("go-latest", flag.ContinueOnError)
+		args := append([]string{"-gobin", t.TempDir(), "-no-cache", "-sync", manifest(t)}, tt.args...)
+		err := runMain(ctx, args, strings.NewReader("y\n"), &b, &b, flags, fakeGo{install: install})
+		if got := exitStatus(err); got != exitUsage || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%q: exit status %d, %v, want %q", tt.args, got, err, tt.want)
+		}
+	}
+}
+
+func TestSubcommandArguments(t *testing.T) {
+	for _, args := range [][]string{{"list", "gopls"}, {"doctor", "gopls"}, {"watch", "gopls"}, {"apply", "-plan", "plan.json", "gopls"}} {
+		var b strings.Builder
+		flags := flag.NewFlagSet("go-latest", flag.ContinueOnError)
+		err := runMain(context.Background(), args, strings.NewReader(""), &b, &b, flags, fakeGo{})
+		if got := exitStatus(err); got != exitUsage || !strings.Contains(err.Error(), "unexpected arguments: gopls") {
+			t.Errorf("%q: exit status %d, %v, want unexpected arguments", args, got, err)
+		}
+	}
+}
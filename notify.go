@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vikblom/go-latest/golatest"
+)
+
+// notifier of the upgrades watch finds, by a POST to url if set or else
+// on the desktop.
+type notifier struct {
+	url    string
+	client *http.Client
+}
+
+// notify of the outdated of results, if any. Not being able to is only
+// logged, the upgrades are there for the next check to find all the same.
+func (n *notifier) notify(ctx context.Context, log *slog.Logger, results []golatest.Result) {
+	var outdated []golatest.Result
+	for _, r := range results {
+		if isOutdated(r) && r.Action != golatest.ActionError && r.Action != golatest.ActionConflict {
+			outdated = append(outdated, r)
+		}
+	}
+	if len(outdated) == 0 {
+		return
+	}
+	var err error
+	if n.url != "" {
+		err = n.post(ctx, outdated)
+	} else {
+		err = desktop(ctx, outdated)
+	}
+	if err != nil {
+		log.Warn("notification not sent", "err", err)
+	}
+}
+
+// post the outdated as JSON to url, like -json prints them, e.g.
+//
+//	{"outdated":[{"file":"/home/me/go/bin/gopls","path":"golang.org/x/tools/gopls",...}]}
+func (n *notifier) post(ctx context.Context, outdated []golatest.Result) error {
+	body, err := json.Marshal(struct {
+		Outdated []golatest.Result `json:"outdated"`
+	}{outdated})
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	res, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+	if res.StatusCode/100 != 2 {
+		return fmt.Errorf("POST %s: %s", n.url, res.Status)
+	}
+	return nil
+}
+
+// desktop notification of the outdated, with notify-send or on macOS
+// osascript, whichever there is.
+func desktop(ctx context.Context, outdated []golatest.Result) error {
+	title := fmt.Sprintf("go-latest: %d upgrades", len(outdated))
+	var lines []string
+	for _, r := range outdated {
+		to := r.Latest
+		if r.GoLatest != "" && (to == "" || to == r.Current) {
+			// Rebuilt with a newer Go.
+			to = r.Current + " " + r.GoLatest
+		}
+		lines = append(lines, fmt.Sprintf("%s %s -> %s", filepath.Base(r.File), r.Current, to))
+	}
+	body := strings.Join(lines, "\n")
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "darwin" {
+		if _, err := exec.LookPath("osascript"); err == nil {
+			// AppleScript strings escape like Go's.
+			cmd = exec.CommandContext(ctx, "osascript", "-e", fmt.Sprintf("display notification %s with title %s", strconv.Quote(body), strconv.Quote(title)))
+		}
+	} else if _, err := exec.LookPath("notify-send"); err == nil {
+		cmd = exec.CommandContext(ctx, "notify-send", "--app-name=go-latest", title, body)
+	}
+	if cmd == nil {
+		return errors.New("no notify-send or osascript to notify with, try -notify-url")
+	}
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s (%w): %s", filepath.Base(cmd.Path), err, bytes.TrimSpace(out))
+	}
+	return nil
+}
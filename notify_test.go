@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/vikblom/go-latest/golatest"
+)
+
+// posted to a test server, the body of each request.
+type posted struct {
+	status int
+	bodies chan []byte
+}
+
+func (p *posted) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" {
+		http.Error(w, "want a JSON POST", http.StatusBadRequest)
+		return
+	}
+	body, _ := io.ReadAll(r.Body)
+	p.bodies <- body
+	w.WriteHeader(p.status)
+}
+
+func TestNotifyPost(t *testing.T) {
+	p := &posted{status: http.StatusNoContent, bodies: make(chan []byte, 1)}
+	srv := httptest.NewServer(p)
+	defer srv.Close()
+
+	var logged strings.Builder
+	log := slog.New(slog.NewTextHandler(&logged, nil))
+	n := &notifier{url: srv.URL, client: srv.Client()}
+	n.notify(context.Background(), log, []golatest.Result{
+		{File: "/bin/gopls", Path: "golang.org/x/tools/gopls", Current: "v0.15.0", Latest: "v0.15.1", Action: golatest.ActionPlanned},
+		{File: "/bin/tool", Path: "example.com/tool", Current: "v1.0.0", Latest: "v1.0.0", Action: golatest.ActionLatest},
+		{File: "/bin/broken", Path: "example.com/broken", Current: "v1.0.0", Latest: "v1.1.0", Action: golatest.ActionError},
+		{File: "/bin/rebuilt", Path: "example.com/rebuilt", Current: "v1.0.0", GoCurrent: "go1.21.0", GoLatest: "go1.22.0", Action: golatest.ActionPlanned},
+	})
+	if logged.Len() > 0 {
+		t.Errorf("logged %s", logged.String())
+	}
+
+	var got struct {
+		Outdated []struct {
+			Path   string `json:"path"`
+			Latest string `json:"latest"`
+		} `json:"outdated"`
+	}
+	select {
+	case body := <-p.bodies:
+		if err := json.Unmarshal(body, &got); err != nil {
+			t.Fatalf("posted %s: %v", body, err)
+		}
+	default:
+		t.Fatal("nothing posted")
+	}
+	if len(got.Outdated) != 2 || got.Outdated[0].Path != "golang.org/x/tools/gopls" || got.Outdated[0].Latest != "v0.15.1" || got.Outdated[1].Path != "example.com/rebuilt" {
+		t.Errorf("posted %+v, want gopls and the rebuilt tool alone", got.Outdated)
+	}
+}
+
+func TestNotifyNothingOutdated(t *testing.T) {
+	p := &posted{status: http.StatusOK, bodies: make(chan []byte, 1)}
+	srv := httptest.NewServer(p)
+	defer srv.Close()
+
+	n := &notifier{url: srv.URL, client: srv.Client()}
+	n.notify(context.Background(), slog.New(slog.NewTextHandler(io.Discard, nil)), []golatest.Result{
+		{File: "/bin/tool", Path: "example.com/tool", Current: "v1.0.0", Latest: "v1.0.0", Action: golatest.ActionLatest},
+	})
+	select {
+	case body := <-p.bodies:
+		t.Errorf("posted %s with nothing outdated", body)
+	default:
+	}
+}
+
+func TestNotifyPostFails(t *testing.T) {
+	p := &posted{status: http.StatusInternalServerError, bodies: make(chan []byte, 1)}
+	srv := httptest.NewServer(p)
+	defer srv.Close()
+
+	var logged strings.Builder
+	log := slog.New(slog.NewTextHandler(&logged, nil))
+	n := &notifier{url: srv.URL, client: srv.Client()}
+	n.notify(context.Background(), log, []golatest.Result{
+		{File: "/bin/tool", Path: "example.com/tool", Current: "v1.0.0", Latest: "v1.1.0", Action: golatest.ActionPlanned},
+	})
+	if s := logged.String(); !strings.Contains(s, "notification not sent") || !strings.Contains(s, "500 Internal Server Error") {
+		t.Errorf("logged %q, want the notification not sent for a 500", s)
+	}
+}
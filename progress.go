@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/vikblom/go-latest/golatest"
+)
+
+// progress of a run on a single terminal line, e.g. "resolving 34/80" or
+// "installing 3/12: gopls, staticcheck", kept out of the way of other
+// output written through it.
+type progress struct {
+	mu  sync.Mutex
+	out io.Writer
+	// phase is empty when there is nothing to show.
+	phase       string
+	done, total int
+	// installing files, in the order they started.
+	installing []string
+	shown      bool
+}
+
+// maxInstalling names shown, the rest are counted.
+const maxInstalling = 3
+
+func newProgress(out io.Writer) *progress {
+	return &progress{out: out}
+}
+
+func (p *progress) event(e golatest.Event) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	switch e.Kind {
+	case golatest.EventResolvePhase:
+		p.phase, p.done, p.total = "resolving", 0, e.Total
+	case golatest.EventInstallPhase:
+		p.phase, p.done, p.total = "installing", 0, e.Total
+	case golatest.EventInstalling:
+		p.installing = append(p.installing, e.Result.File)
+	case golatest.EventInstalled:
+		p.done++
+		if i := slices.Index(p.installing, e.Result.File); i >= 0 {
+			p.installing = slices.Delete(p.installing, i, i+1)
+		}
+	case golatest.EventResolved:
+		p.done++
+	default:
+		return
+	}
+	p.draw()
+}
+
+// stop showing progress.
+func (p *progress) stop() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.clear()
+	p.phase = ""
+}
+
+func (p *progress) draw() {
+	if p.phase == "" || p.total == 0 {
+		p.clear()
+		return
+	}
+	line := fmt.Sprintf("%s %d/%d", p.phase, p.done, p.total)
+	if len(p.installing) > 0 {
+		var names []string
+		for _, f := range p.installing[:min(len(p.installing), maxInstalling)] {
+			names = append(names, filepath.Base(f))
+		}
+		if more := len(p.installing) - maxInstalling; more > 0 {
+			names = append(names, fmt.Sprintf("+%d", more))
+		}
+		line += ": " + strings.Join(names, ", ")
+	}
+	fmt.Fprintf(p.out, "\r\x1b[K%s", line)
+	p.shown = true
+}
+
+func (p *progress) clear() {
+	if p.shown {
+		fmt.Fprint(p.out, "\r\x1b[K")
+		p.shown = false
+	}
+}
+
+// writer wraps w, clearing the progress line for whatever is written to w.
+func (p *progress) writer(w io.Writer) io.Writer {
+	return progressWriter{p: p, w: w}
+}
+
+type progressWriter struct {
+	p *progress
+	w io.Writer
+}
+
+func (pw progressWriter) Write(b []byte) (int, error) {
+	pw.p.mu.Lock()
+	defer pw.p.mu.Unlock()
+	pw.p.clear()
+	n, err := pw.w.Write(b)
+	pw.p.draw()
+	return n, err
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+// openPty returns the controlling and terminal ends of a new pty.
+func openPty(t *testing.T) (ptm, pts *os.File) {
+	t.Helper()
+	ptm, err := os.OpenFile("/dev/ptmx", os.O_RDWR, 0)
+	if err != nil {
+		t.Skipf("no pty: %v", err)
+	}
+	err = unix.IoctlSetPointerInt(int(ptm.Fd()), unix.TIOCSPTLCK, 0)
+	if err != nil {
+		ptm.Close()
+		t.Skipf("no pty: %v", err)
+	}
+	n, err := unix.IoctlGetInt(int(ptm.Fd()), unix.TIOCGPTN)
+	if err != nil {
+		ptm.Close()
+		t.Skipf("no pty: %v", err)
+	}
+	pts, err = os.OpenFile("/dev/pts/"+strconv.Itoa(n), os.O_RDWR|unix.O_NOCTTY, 0)
+	if err != nil {
+		ptm.Close()
+		t.Skipf("no pty: %v", err)
+	}
+	return ptm, pts
+}
+
+func TestProgressOnlyOnTerminal(t *testing.T) {
+	for _, tt := range []struct {
+		name         string
+		stdoutTTY    bool
+		args         []string
+		wantProgress bool
+	}{
+		{name: "terminal", stdoutTTY: true, wantProgress: true},
+		{name: "stdout piped", stdoutTTY: false},
+		{name: "json", stdoutTTY: true, args: []string{"-json"}},
+		{name: "json stream", stdoutTTY: true, args: []string{"-json-stream"}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			wd, err := os.Getwd()
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { os.Chdir(wd) })
+
+			ptm, pts := openPty(t)
+			defer ptm.Close()
+			read := make(chan string)
+			go func() {
+				// Until pts is closed, then EIO.
+				b, _ := io.ReadAll(ptm)
+				read <- string(b)
+			}()
+
+			var stdout io.Writer = &bytes.Buffer{}
+			if tt.stdoutTTY {
+				stdout = pts
+			}
+			args := append([]string{"-gobin", filepath.Join(t.TempDir(), "bin"), "-no-cache", "-color", "never", "-sync", manifest(t)}, tt.args...)
+			runner := fakeGo{install: func(context.Context, ...string) error { return nil }}
+			flags := flag.NewFlagSet("go-latest", flag.ContinueOnError)
+			err = runMain(context.Background(), args, strings.NewReader(""), stdout, pts, flags, runner)
+			pts.Close()
+			if err != nil {
+				t.Fatalf("runMain: %v", err)
+			}
+			out := <-read
+			if got := strings.Contains(out, "\x1b[K"); got != tt.wantProgress {
+				t.Errorf("progress shown %t, want %t, terminal got %q", got, tt.wantProgress, out)
+			}
+		})
+	}
+}
@@ -0,0 +1,786 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log/slog"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"text/template"
+	"time"
+	"unicode/utf8"
+
+	"github.com/vikblom/go-latest/golatest"
+)
+
+// maxPathWidth of the path column of a table, unless wide.
+const maxPathWidth = 48
+
+// notable results, those that changed something or failed.
+func notable(results []golatest.Result) []golatest.Result {
+	var rs []golatest.Result
+	for _, r := range results {
+		if !isNoise(r) {
+			rs = append(rs, r)
+		}
+	}
+	return rs
+}
+
+// isOutdated a result whose program was behind, in the version of its
+// module or of Go, whatever came of it.
+func isOutdated(r golatest.Result) bool {
+	// Upgrades may be to the same version of a module it since moved to.
+	return r.Action == golatest.ActionUpgrade || r.GoLatest != "" || r.Latest != "" && r.Latest != r.Current
+}
+
+// isNoise a result which neither changed anything nor failed.
+func isNoise(r golatest.Result) bool {
+	switch r.Action {
+	case golatest.ActionSkip, golatest.ActionLatest, golatest.ActionDeclined, golatest.ActionHeld:
+		return true
+	}
+	return false
+}
+
+// statusRank of actions when sorting by status, failures first.
+var statusRank = map[string]int{
+	golatest.ActionError:     0,
+	golatest.ActionConflict:  1,
+	golatest.ActionOrphaned:  2,
+	golatest.ActionUpgrade:   3,
+	golatest.ActionInstall:   4,
+	golatest.ActionReinstall: 5,
+	golatest.ActionRemoved:   6,
+	golatest.ActionPlanned:   7,
+	golatest.ActionDeclined:  8,
+	golatest.ActionHeld:      9,
+	golatest.ActionLatest:    10,
+	golatest.ActionSkip:      11,
+}
+
+// sortResults by path, status or duration, the longest lookup and
+// install first. Ties are in order of path, then file, so that the
+// order the results came in doesn't matter.
+func sortResults(results []golatest.Result, by string) {
+	sort.Slice(results, func(i, j int) bool {
+		a, b := results[i], results[j]
+		switch by {
+		case "status":
+			if statusRank[a.Action] != statusRank[b.Action] {
+				return statusRank[a.Action] < statusRank[b.Action]
+			}
+		case "duration":
+			if da, db := a.Lookup+a.Download+a.Duration, b.Lookup+b.Download+b.Duration; da != db {
+				return da > db
+			}
+		}
+		if a.Path != b.Path {
+			return a.Path < b.Path
+		}
+		return a.File < b.File
+	})
+}
+
+// report each result in a record of its own, with timings the time
+// taken to look up the latest version as well.
+func report(log *slog.Logger, results []golatest.Result, timings bool) {
+	for _, r := range results {
+		reportResult(log, r, timings)
+	}
+}
+
+func reportResult(log *slog.Logger, r golatest.Result, timings bool) {
+	level, msg := slog.LevelInfo, r.Action
+	switch r.Action {
+	case "":
+		// Cut short by cancellation.
+		return
+	case golatest.ActionLatest:
+		msg = "already latest"
+	case golatest.ActionSkip:
+		if r.Local {
+			msg = localBuild
+		} else if r.Skipped != "" {
+			msg += ", " + r.Skipped
+		}
+	case golatest.ActionHeld:
+		msg = held(r)
+	case golatest.ActionReinstall:
+		msg = "forced reinstall"
+	case golatest.ActionInstall:
+		msg = "installed"
+	case golatest.ActionOrphaned:
+		level = slog.LevelWarn
+	case golatest.ActionConflict:
+		level = slog.LevelError
+	case golatest.ActionError:
+		level, msg = slog.LevelError, "failed"
+	}
+	attrs := []any{"path", r.Path}
+	add := func(key, value string) {
+		if value != "" {
+			attrs = append(attrs, key, value)
+		}
+	}
+	add("file", r.File)
+	add("module", r.Module)
+	add("current", r.Current)
+	add("latest", r.Latest)
+	if r.GoLatest != "" {
+		add("go_current", r.GoCurrent)
+		add("go_latest", r.GoLatest)
+	}
+	attrs = append(attrs, "action", r.Action)
+	if r.Duration > 0 {
+		attrs = append(attrs, "duration", r.Duration)
+	}
+	add("size", sizeDelta(r))
+	add("attempts", attempts(r))
+	add("released", released(r))
+	add("versions", versionsNote(r))
+	add("changes", r.Changes)
+	if notes := vulnNotes(r); notes != nil {
+		attrs = append(attrs, "vulns", notes)
+	}
+	if timings && r.Lookup > 0 {
+		attrs = append(attrs, "lookup", round(r.Lookup))
+	}
+	if timings && r.Download > 0 {
+		attrs = append(attrs, "download", round(r.Download))
+	}
+	if r.Err != nil {
+		attrs = append(attrs, "err", r.Err)
+	}
+	log.Log(context.Background(), level, msg, attrs...)
+}
+
+// reportSlowest n results by lookup and install time combined.
+func reportSlowest(log *slog.Logger, results []golatest.Result, n int) {
+	for _, r := range slowest(results, n) {
+		log.Info("slowest", "path", r.Path, "lookup", round(r.Lookup), "download", round(r.Download), "install", round(r.Duration))
+	}
+}
+
+// slowest n results by lookup and install time combined.
+func slowest(results []golatest.Result, n int) []golatest.Result {
+	var rs []golatest.Result
+	for _, r := range results {
+		if r.Lookup+r.Download+r.Duration > 0 {
+			rs = append(rs, r)
+		}
+	}
+	sort.SliceStable(rs, func(i, j int) bool {
+		return rs[i].Lookup+rs[i].Download+rs[i].Duration > rs[j].Lookup+rs[j].Download+rs[j].Duration
+	})
+	return rs[:min(n, len(rs))]
+}
+
+// round d for humans, to the millisecond below a second.
+func round(d time.Duration) time.Duration {
+	if d < time.Second {
+		return d.Round(time.Millisecond)
+	}
+	return d.Round(100 * time.Millisecond)
+}
+
+// streamer reports results as soon as they are final, rather than
+// all at once when done.
+type streamer struct {
+	log     *slog.Logger
+	timings bool
+	// hide results for which it is true, if set.
+	hide func(golatest.Result) bool
+
+	mu sync.Mutex
+	// reported results, by file and path since conflicts have no file.
+	reported map[[2]string]bool
+}
+
+func newStreamer(log *slog.Logger, timings bool, hide func(golatest.Result) bool) *streamer {
+	return &streamer{log: log, timings: timings, hide: hide, reported: map[[2]string]bool{}}
+}
+
+func (s *streamer) event(e golatest.Event) {
+	if e.Kind != golatest.EventResolved && e.Kind != golatest.EventInstalled {
+		return
+	}
+	if e.Result.Action == "" {
+		// Resolved to an upgrade, reported once installed.
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reported[[2]string{e.Result.File, e.Result.Path}] = true
+	if s.hide == nil || !s.hide(e.Result) {
+		reportResult(s.log, e.Result, s.timings)
+	}
+}
+
+// flush reports the results that never came by as events.
+func (s *streamer) flush(results []golatest.Result) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, r := range results {
+		if !s.reported[[2]string{r.File, r.Path}] && (s.hide == nil || !s.hide(r)) {
+			reportResult(s.log, r, s.timings)
+		}
+	}
+}
+
+// table of results, one aligned row each:
+//
+//	golang.org/x/tools/gopls         v0.9.5  -> v0.10.0 (12.3s)
+//	golang.org/x/tools/cmd/stringer  v0.3.0  already latest
+//
+// Errors spanning several lines are written out in full below it.
+// With timings, how long the lookup and install took go in columns
+// of their own, and the slowest programs are listed last.
+func table(w io.Writer, results []golatest.Result, color, wide, timings bool) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	var details []golatest.Result
+	for _, r := range results {
+		if r.Action == "" {
+			continue
+		}
+		p := r.Path
+		if !wide {
+			p = shorten(p, maxPathWidth)
+		}
+		current := r.Current
+		if r.GoLatest != "" {
+			current += " (" + r.GoCurrent + ")"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t", p, current)
+		if timings {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t", timing("lookup", r.Lookup), timing("download", r.Download), timing("install", r.Duration))
+		}
+		fmt.Fprintf(tw, "%s\n", status(r, color))
+		// Under the status, in rows of their own.
+		notes := vulnNotes(r)
+		for i := range notes {
+			notes[i] = paint(color, colorYellow, notes[i])
+		}
+		if v := versionsNote(r); v != "" {
+			notes = append(notes, paint(color, colorDim, v))
+		}
+		if r.Changes != "" {
+			notes = append(notes, paint(color, colorDim, r.Changes))
+		}
+		for _, note := range notes {
+			fmt.Fprint(tw, "\t\t")
+			if timings {
+				fmt.Fprint(tw, "\t\t\t")
+			}
+			fmt.Fprintf(tw, "%s\n", note)
+		}
+		if r.Err != nil && strings.Contains(strings.TrimSpace(r.Err.Error()), "\n") {
+			details = append(details, r)
+		}
+	}
+	err := tw.Flush()
+	if err != nil {
+		return err
+	}
+	for _, r := range details {
+		// The first line is in the status already.
+		_, rest, _ := strings.Cut(strings.TrimSpace(r.Err.Error()), "\n")
+		_, err = fmt.Fprintf(w, "\n%s", diagnostics(r.File, r.Path, rest))
+		if err != nil {
+			return err
+		}
+	}
+
+	slow := slowest(results, 5)
+	if !timings || len(slow) == 0 {
+		return nil
+	}
+	fmt.Fprintf(tw, "\nslowest:\n")
+	for _, r := range slow {
+		p := r.Path
+		if !wide {
+			p = shorten(p, maxPathWidth)
+		}
+		fmt.Fprintf(tw, "  %s\t%s\t%s\t%s\n", p, timing("lookup", r.Lookup), timing("download", r.Download), timing("install", r.Duration))
+	}
+	return tw.Flush()
+}
+
+// grouped table of results, in sections of those that changed, those that
+// failed with their errors in full below each, and a count of the rest.
+func grouped(w io.Writer, results []golatest.Result, color, wide, timings bool) error {
+	var changed, failed []golatest.Result
+	unchanged := 0
+	for _, r := range results {
+		switch r.Action {
+		case "":
+		case golatest.ActionError, golatest.ActionConflict, golatest.ActionOrphaned:
+			failed = append(failed, r)
+		default:
+			if isNoise(r) {
+				unchanged++
+			} else {
+				changed = append(changed, r)
+			}
+		}
+	}
+
+	if len(changed) > 0 {
+		fmt.Fprintln(w, paint(color, colorBold, "changed:"))
+		err := table(w, changed, color, wide, timings)
+		if err != nil {
+			return err
+		}
+	}
+	if len(failed) > 0 {
+		if len(changed) > 0 {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintln(w, paint(color, colorBold, "failed:"))
+		// Aligned by hand, tabwriter would realign after every error.
+		paths := make([]string, len(failed))
+		pathWidth, currentWidth := 0, 0
+		for i, r := range failed {
+			paths[i] = r.Path
+			if !wide {
+				paths[i] = shorten(r.Path, maxPathWidth)
+			}
+			pathWidth = max(pathWidth, utf8.RuneCountInString(paths[i]))
+			currentWidth = max(currentWidth, len(r.Current))
+		}
+		for i, r := range failed {
+			pad := strings.Repeat(" ", pathWidth-utf8.RuneCountInString(paths[i]))
+			fmt.Fprintf(w, "%s%s  %-*s  %s\n", paths[i], pad, currentWidth, r.Current, status(r, color))
+			if r.Err != nil {
+				// The first line is in the status already.
+				if _, rest, ok := strings.Cut(strings.TrimSpace(r.Err.Error()), "\n"); ok {
+					fmt.Fprint(w, diagnostics(r.File, r.Path, rest))
+				}
+			}
+		}
+	}
+	if unchanged > 0 {
+		if len(changed)+len(failed) > 0 {
+			fmt.Fprintln(w)
+		}
+		_, err := fmt.Fprintln(w, paint(color, colorDim, fmt.Sprintf("%d unchanged", unchanged)))
+		return err
+	}
+	return nil
+}
+
+// diagnostics block of out, the output of the program in file, or of pkg
+// if there is none, after its name:
+//
+//	=== gopls ===
+//	go: downloading golang.org/x/tools v0.20.0
+//	...
+func diagnostics(file, pkg, out string) string {
+	name := filepath.Base(file)
+	if file == "" {
+		name = path.Base(pkg)
+	}
+	return "=== " + name + " ===\n" + strings.TrimRight(out, "\n") + "\n"
+}
+
+// timing of what took d, e.g. "install 12.3s", if any.
+func timing(what string, d time.Duration) string {
+	if d == 0 {
+		return ""
+	}
+	return what + " " + round(d).String()
+}
+
+// status of a result in a table, e.g. "-> v0.10.0 (12.3s)".
+func status(r golatest.Result, color bool) string {
+	dur := took(released(r), r.Duration.String(), sizeDelta(r), attempts(r))
+	switch r.Action {
+	case golatest.ActionUpgrade:
+		latest := r.Latest
+		if r.GoLatest != "" {
+			latest += " (" + r.GoLatest + ")"
+		}
+		return paint(color, colorGreen, "-> ") + paint(color, colorGreen+colorBold, latest) + dur
+	case golatest.ActionInstall:
+		return paint(color, colorGreen, "installed ") + paint(color, colorGreen+colorBold, r.Latest) + dur
+	case golatest.ActionReinstall:
+		return paint(color, colorGreen, "forced reinstall") + took("", r.Duration.String(), sizeDelta(r), attempts(r))
+	case golatest.ActionRemoved:
+		return paint(color, colorGreen, "removed")
+	case golatest.ActionLatest:
+		return paint(color, colorDim, "already latest")
+	case golatest.ActionSkip:
+		msg := r.Action
+		if r.Local {
+			msg = localBuild
+		} else if r.Skipped != "" {
+			msg += ", " + r.Skipped
+		}
+		// The version alone tells little of a local build.
+		if v := vcs(r); v != "" {
+			msg += " " + v
+		}
+		return paint(color, colorDim, msg)
+	case golatest.ActionDeclined:
+		return paint(color, colorDim, r.Action)
+	case golatest.ActionHeld:
+		return paint(color, colorDim, held(r))
+	case golatest.ActionPlanned:
+		latest := r.Latest
+		if latest == "" {
+			latest = "latest"
+		}
+		note := " (dry run)"
+		if released(r) != "" {
+			note = " (released " + ago(time.Since(r.Released)) + ", dry run)"
+		}
+		return paint(color, colorGreen, "-> ") + paint(color, colorGreen+colorBold, latest) + note
+	}
+	msg, c := r.Action, colorRed
+	switch r.Action {
+	case golatest.ActionError:
+		msg = "failed"
+	case golatest.ActionOrphaned:
+		c = colorYellow
+	}
+	if r.Err != nil {
+		msg += ": " + firstLine(r.Err.Error())
+	}
+	return paint(color, c, msg)
+}
+
+// vulnNotes on the known vulnerabilities of r, one each, e.g.
+// "GO-2024-2687 in golang.org/x/net v0.17.0, fixed in v0.23.0".
+func vulnNotes(r golatest.Result) []string {
+	if r.VulnErr != nil {
+		return []string{"vuln status unknown"}
+	}
+	var notes []string
+	for _, v := range r.Vulns {
+		note := v.ID + " in " + v.Module + " " + v.Version
+		switch {
+		case r.Fixes(v) && v.Module == "stdlib":
+			note += ", fixed by " + r.GoLatest
+		case r.Fixes(v):
+			note += ", fixed by " + r.Latest
+		case v.Fixed != "":
+			note += ", fixed in " + v.Fixed
+		default:
+			note += ", not fixed"
+		}
+		notes = append(notes, note)
+	}
+	return notes
+}
+
+// attempts it took to install r, e.g. "after 2 attempts", if more than one.
+func attempts(r golatest.Result) string {
+	if r.Attempts < 2 {
+		return ""
+	}
+	return fmt.Sprintf("after %d attempts", r.Attempts)
+}
+
+// maxVersions listed in full by versionsNote, more are elided.
+const maxVersions = 5
+
+// versionsNote on the versions released from Current to Latest of r, e.g.
+// "2 releases: v1.0.1, v1.1.0", or empty if not known.
+func versionsNote(r golatest.Result) string {
+	vs := r.Versions
+	if len(vs) == 0 {
+		return ""
+	}
+	if len(vs) > maxVersions {
+		vs = []string{vs[0], "...", vs[len(vs)-1]}
+	}
+	unit := "releases"
+	if len(r.Versions) == 1 {
+		unit = "release"
+	}
+	return fmt.Sprintf("%d %s: %s", len(r.Versions), unit, strings.Join(vs, ", "))
+}
+
+// localBuild is why a result of a local build was skipped.
+const localBuild = "local build, not upgradable"
+
+// fixedVulns by the upgrades among results, the IDs of them.
+func fixedVulns(results []golatest.Result) []string {
+	var ids []string
+	for _, r := range results {
+		if r.Action != golatest.ActionUpgrade && r.Action != golatest.ActionPlanned {
+			continue
+		}
+		for _, v := range r.Vulns {
+			if r.Fixes(v) {
+				ids = append(ids, v.ID)
+			}
+		}
+	}
+	sort.Strings(ids)
+	return slices.Compact(ids)
+}
+
+// held is why r was held back, with -only-vulnerable.
+func held(r golatest.Result) string {
+	if len(r.Vulns) > 0 {
+		return "held (fixes no known vulnerabilities)"
+	}
+	return "held (no known vulnerabilities)"
+}
+
+// sizeDelta of the file of r by installing it, e.g. "+1.2 MB", if installed.
+func sizeDelta(r golatest.Result) string {
+	if r.SizeAfter == 0 {
+		return ""
+	}
+	return humanBytes(r.SizeAfter - r.SizeBefore)
+}
+
+// humanBytes change of n bytes, e.g. "+1.2 MB" or "-340.0 kB".
+func humanBytes(n int64) string {
+	abs := n
+	if abs < 0 {
+		abs = -abs
+	}
+	switch {
+	case abs >= 1e6:
+		return fmt.Sprintf("%+.1f MB", float64(n)/1e6)
+	case abs >= 1e3:
+		return fmt.Sprintf("%+.1f kB", float64(n)/1e3)
+	}
+	return fmt.Sprintf("%+d B", n)
+}
+
+// released time of the version r was upgraded to, if known.
+func released(r golatest.Result) string {
+	switch r.Action {
+	case golatest.ActionUpgrade, golatest.ActionInstall, golatest.ActionPlanned:
+	default:
+		return ""
+	}
+	if r.Released.IsZero() || r.Latest == r.Current {
+		return ""
+	}
+	return r.Released.Format(time.RFC3339)
+}
+
+// ago humanizes an age d, e.g. "3 weeks ago".
+func ago(d time.Duration) string {
+	day := 24 * time.Hour
+	var n int
+	var unit string
+	switch {
+	case d < time.Hour:
+		return "just now"
+	case d < day:
+		n, unit = int(d/time.Hour), "hour"
+	case d < 14*day:
+		n, unit = int(d/day), "day"
+	case d < 60*day:
+		n, unit = int(d/(7*day)), "week"
+	case d < 365*day:
+		n, unit = int(d/(30*day)), "month"
+	default:
+		n, unit = int(d/(365*day)), "year"
+	}
+	if n != 1 {
+		unit += "s"
+	}
+	return fmt.Sprintf("%d %s ago", n, unit)
+}
+
+// vcs commit the current version of r was built from, if stamped,
+// e.g. "[abc1234 2024-03-02, dirty]".
+func vcs(r golatest.Result) string {
+	if r.Revision == "" {
+		return ""
+	}
+	v := r.Revision[:min(len(r.Revision), 7)]
+	if r.RevisionTime != "" {
+		v += " " + r.RevisionTime[:min(len(r.RevisionTime), len("2006-01-02"))]
+	}
+	if r.Modified {
+		v += ", dirty"
+	}
+	return "[" + v + "]"
+}
+
+// shorten p to at most n runes by cutting from the left,
+// keeping the more telling end of a package path.
+func shorten(p string, n int) string {
+	if utf8.RuneCountInString(p) <= n {
+		return p
+	}
+	r := []rune(p)
+	return "…" + string(r[len(r)-n+1:])
+}
+
+// templateResult is what a -format template is executed with, per program.
+// Its fields are documented in the help text.
+type templateResult struct {
+	// Name of the binary.
+	Name string
+	// Path of the package.
+	Path   string
+	Module string
+	// Installed version, before any upgrade.
+	Installed string
+	// Target version, the latest.
+	Target string
+	Action string
+	// Error message, if failed.
+	Error string
+	// GoVersion the program was built with, before any upgrade.
+	GoVersion string
+	// Duration of the install, if any.
+	Duration time.Duration
+}
+
+// formatResults to w with tmpl, one line each.
+// Results the template fails on are logged, not written.
+func formatResults(w io.Writer, log *slog.Logger, tmpl *template.Template, results []golatest.Result) error {
+	for _, r := range results {
+		if r.Action == "" {
+			continue
+		}
+		tr := templateResult{
+			Path:      r.Path,
+			Module:    r.Module,
+			Installed: r.Current,
+			Target:    r.Latest,
+			Action:    r.Action,
+			GoVersion: r.GoCurrent,
+			Duration:  r.Duration,
+		}
+		if r.File != "" {
+			tr.Name = filepath.Base(r.File)
+		}
+		if r.Err != nil {
+			tr.Error = r.Err.Error()
+		}
+		var b bytes.Buffer
+		err := tmpl.Execute(&b, tr)
+		if err != nil {
+			log.Error("format failed", "path", r.Path, "err", err)
+			continue
+		}
+		if !bytes.HasSuffix(b.Bytes(), []byte("\n")) {
+			b.WriteString("\n")
+		}
+		_, err = w.Write(b.Bytes())
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// csvResults to w, a header row and then a row per program.
+func csvResults(w io.Writer, results []golatest.Result) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"name", "module", "installed", "latest", "action", "go_version", "error"})
+	for _, r := range results {
+		if r.Action == "" {
+			continue
+		}
+		var name, msg string
+		if r.File != "" {
+			name = filepath.Base(r.File)
+		}
+		if r.Err != nil {
+			msg = r.Err.Error()
+		}
+		cw.Write([]string{name, r.Module, r.Current, r.Latest, r.Action, r.GoCurrent, msg})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// markdownResults to w, a table of programs under a heading of when and
+// where it ran, with totals:
+//
+//	## go-latest 2024-05-01 12:00
+//
+//	8 programs in `/home/gopher/go/bin`: 1 error, 2 upgrade, 5 latest
+//
+//	| Program | Path | Installed | Latest | Status |
+//	|---|---|---|---|---|
+//	| **gopls** | **golang.org/x/tools/gopls** | v0.9.5 | **[v0.10.0](https://github.com/golang/tools/compare/gopls/v0.9.5...gopls/v0.10.0)** | **-\> v0.10.0 (12.3s)** |
+//
+// Upgrades are in bold, linking to their changes if known.
+func markdownResults(w io.Writer, results []golatest.Result, dir string, now time.Time) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "## go-latest %s\n\n", now.Format("2006-01-02 15:04"))
+	count := map[string]int{}
+	var actions []string
+	n := 0
+	for _, r := range results {
+		if r.Action == "" {
+			continue
+		}
+		if count[r.Action] == 0 {
+			actions = append(actions, r.Action)
+		}
+		count[r.Action]++
+		n++
+	}
+	sort.Slice(actions, func(i, j int) bool { return statusRank[actions[i]] < statusRank[actions[j]] })
+	var totals []string
+	for _, a := range actions {
+		totals = append(totals, fmt.Sprintf("%d %s", count[a], a))
+	}
+	fmt.Fprintf(&b, "%d programs in `%s`: %s\n\n", n, dir, strings.Join(totals, ", "))
+
+	b.WriteString("| Program | Path | Installed | Latest | Status |\n|---|---|---|---|---|\n")
+	for _, r := range results {
+		if r.Action == "" {
+			continue
+		}
+		var name string
+		if r.File != "" {
+			name = filepath.Base(r.File)
+		}
+		st := status(r, false)
+		if notes := vulnNotes(r); notes != nil {
+			st += "; " + strings.Join(notes, "; ")
+		}
+		cells := []string{name, r.Path, r.Current, r.Latest, st}
+		for i, c := range cells {
+			c = markdownEscape(c)
+			if i == 3 && c != "" && r.Changes != "" {
+				c = "[" + c + "](" + r.Changes + ")"
+			}
+			if c != "" && r.Action == golatest.ActionUpgrade && i != 2 {
+				c = "**" + c + "**"
+			}
+			cells[i] = c
+		}
+		b.WriteString("| " + strings.Join(cells, " | ") + " |\n")
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// markdownEscape s for a table cell, keeping it to a single line.
+func markdownEscape(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch r {
+		case '\\', '`', '*', '_', '[', ']', '<', '>', '|', '#':
+			b.WriteRune('\\')
+		case '\n':
+			r = ' '
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"errors"
+	"log/slog"
+	"math/rand"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vikblom/go-latest/golatest"
+)
+
+func TestSortResults(t *testing.T) {
+	// By path, as Upgrade returns them.
+	results := []golatest.Result{
+		{Path: "a", Action: golatest.ActionLatest, Lookup: time.Second},
+		{Path: "b", Action: golatest.ActionError, Lookup: 2 * time.Second},
+		{Path: "c", Action: golatest.ActionUpgrade, Lookup: time.Second, Duration: 3 * time.Second},
+		{Path: "d", Action: golatest.ActionError, Download: 3 * time.Second},
+		{Path: "e", Action: golatest.ActionSkip},
+	}
+	for _, tt := range []struct {
+		by   string
+		want []string
+	}{
+		{"path", []string{"a", "b", "c", "d", "e"}},
+		// Ties in path order.
+		{"status", []string{"b", "d", "c", "a", "e"}},
+		{"duration", []string{"c", "d", "b", "a", "e"}},
+	} {
+		rs := slices.Clone(results)
+		sortResults(rs, tt.by)
+		var got []string
+		for _, r := range rs {
+			got = append(got, r.Path)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("sorted by %s: %q, want %q", tt.by, got, tt.want)
+		}
+	}
+}
+
+func TestSortResultsShuffled(t *testing.T) {
+	results := []golatest.Result{
+		{Path: "a", File: "/bin/a", Action: golatest.ActionLatest, Lookup: time.Second},
+		{Path: "a", File: "/bin/a-copy", Action: golatest.ActionLatest, Lookup: time.Second},
+		{Path: "b", File: "/bin/b", Action: golatest.ActionError, Err: errors.New("exit status 1"), Lookup: time.Second},
+		{Path: "c", File: "/bin/c", Action: golatest.ActionUpgrade, Current: "v1.0.0", Latest: "v1.1.0", Duration: time.Second},
+		{Path: "d", File: "/bin/d", Action: golatest.ActionUpgrade, Current: "v1.0.0", Latest: "v1.1.0", Duration: time.Second},
+		{Path: "e", File: "/bin/e", Action: golatest.ActionSkip},
+	}
+	for _, tt := range []struct {
+		by, want string
+	}{
+		{"path", "/bin/a /bin/a-copy /bin/b /bin/c /bin/d /bin/e"},
+		{"status", "/bin/b /bin/c /bin/d /bin/a /bin/a-copy /bin/e"},
+		{"duration", "/bin/a /bin/a-copy /bin/b /bin/c /bin/d /bin/e"},
+	} {
+		by := tt.by
+		// Workers finish in any order.
+		var firstSummary, firstTable string
+		for i := 0; i < 20; i++ {
+			rs := slices.Clone(results)
+			rand.New(rand.NewSource(int64(i))).Shuffle(len(rs), func(i, j int) { rs[i], rs[j] = rs[j], rs[i] })
+			sortResults(rs, by)
+			var files []string
+			for _, r := range rs {
+				files = append(files, r.File)
+			}
+			var summary, tbl strings.Builder
+			summarize(slog.New(slog.NewJSONHandler(&summary, nil)), rs, time.Second, false)
+			if err := table(&tbl, rs, false, false, false); err != nil {
+				t.Fatal(err)
+			}
+			// But for the time of the record.
+			_, got, _ := strings.Cut(summary.String(), `"level"`)
+			if strings.Join(files, " ") != tt.want {
+				t.Errorf("sorted by %s, shuffled %d: %s, want %s", by, i, strings.Join(files, " "), tt.want)
+			}
+			if i == 0 {
+				firstSummary, firstTable = got, tbl.String()
+				continue
+			}
+			if got != firstSummary {
+				t.Errorf("sorted by %s, shuffled %d: summary %s, want %s", by, i, got, firstSummary)
+			}
+			if tbl.String() != firstTable {
+				t.Errorf("sorted by %s, shuffled %d: table\n%s, want\n%s", by, i, tbl.String(), firstTable)
+			}
+		}
+	}
+}
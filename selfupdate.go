@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"strings"
+
+	"github.com/vikblom/go-latest/golatest"
+	"golang.org/x/mod/semver"
+)
+
+// selfUpdate go-latest to its latest version with runner, writing the
+// versions before and after to w.
+func selfUpdate(ctx context.Context, w io.Writer, runner golatest.Runner) error {
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return errors.New("self-update: could not read buildinfo")
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("self-update: %w", err)
+	}
+	exe, err = filepath.EvalSymlinks(exe)
+	if err != nil {
+		return fmt.Errorf("self-update: %w", err)
+	}
+	return update(ctx, w, runner, bi, exe)
+}
+
+// update exe, go-latest as built per bi, to its latest version with
+// runner, writing the versions before and after to w along with any
+// warnings.
+// The latest version is looked up first, then installed next to exe, run to
+// check that it is the version installed and renamed over exe, out of the
+// way first on Windows where a running exe can't be replaced.
+func update(ctx context.Context, w io.Writer, runner golatest.Runner, bi *debug.BuildInfo, exe string) error {
+	if bi.Main.Version == "" || bi.Main.Version == "(devel)" {
+		return fmt.Errorf("self-update: %s was not go install-ed at a version", bi.Path)
+	}
+	dir, err := os.MkdirTemp(filepath.Dir(exe), ".go-latest-")
+	if err != nil {
+		return fmt.Errorf("self-update: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	opts := golatest.Options{
+		Dir:    dir,
+		Env:    []string{"GOBIN=" + dir},
+		DryRun: true,
+		Sync:   &golatest.Manifest{Tools: []golatest.Tool{{Path: bi.Path}}},
+		Runner: runner,
+		Log:    slog.New(newHumanHandler(w, &slog.HandlerOptions{Level: slog.LevelWarn}, false)),
+	}
+	results, err := golatest.New(opts).Upgrade(ctx)
+	if err != nil {
+		return fmt.Errorf("self-update: %w", err)
+	}
+	if len(results) != 1 || results[0].Action != golatest.ActionPlanned {
+		if len(results) == 1 && results[0].Err != nil {
+			return fmt.Errorf("self-update: %w", results[0].Err)
+		}
+		return errors.New("self-update: latest version unknown")
+	}
+	latest := results[0]
+	switch semver.Compare(latest.Latest, bi.Main.Version) {
+	case 0:
+		_, err = fmt.Fprintf(w, "%s %s already latest\n", bi.Path, bi.Main.Version)
+		return err
+	case -1:
+		_, err = fmt.Fprintf(w, "%s %s newer than latest %s\n", bi.Path, bi.Main.Version, latest.Latest)
+		return err
+	}
+
+	// Pinned to the version looked up.
+	opts.DryRun = false
+	opts.Sync = &golatest.Manifest{Tools: []golatest.Tool{{Path: bi.Path, Module: latest.Module, Version: latest.Latest}}}
+	results, err = golatest.New(opts).Upgrade(ctx)
+	if err != nil {
+		return fmt.Errorf("self-update: %w", err)
+	}
+	if len(results) != 1 || results[0].Action != golatest.ActionInstall {
+		if len(results) == 1 && results[0].Err != nil {
+			return fmt.Errorf("self-update: %w", results[0].Err)
+		}
+		return errors.New("self-update: nothing installed")
+	}
+	res := results[0]
+
+	out, err := exec.CommandContext(ctx, res.File, "-v").Output()
+	if err != nil {
+		return fmt.Errorf("self-update: running %s@%s: %w", bi.Path, res.Latest, err)
+	}
+	if v := strings.TrimSpace(string(out)); v != res.Latest {
+		return fmt.Errorf("self-update: installed %s but it says it is %s", res.Latest, v)
+	}
+
+	old := ""
+	if runtime.GOOS == "windows" {
+		old = exe + ".old"
+		os.Remove(old)
+		err = os.Rename(exe, old)
+		if err != nil {
+			return fmt.Errorf("self-update: %w", err)
+		}
+	}
+	err = os.Rename(res.File, exe)
+	if err != nil {
+		if old != "" {
+			// Lest there be no go-latest left at all.
+			if rerr := os.Rename(old, exe); rerr != nil {
+				return fmt.Errorf("self-update: %w, and restoring %s: %w", err, exe, rerr)
+			}
+		}
+		return fmt.Errorf("self-update: %w", err)
+	}
+	_, err = fmt.Fprintf(w, "%s %s -> %s\n", bi.Path, bi.Main.Version, res.Latest)
+	return err
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// goSelf runs go list -m answering latest for mod alone, and go install
+// writing a script saying the version installed to GOBIN, recording each.
+type goSelf struct {
+	mod, latest string
+
+	mu       sync.Mutex
+	installs []string
+}
+
+func (g *goSelf) Run(_ context.Context, env []string, args ...string) ([]byte, []byte, error) {
+	switch args[0] {
+	case "list":
+		var out []byte
+		for _, arg := range args[1:] {
+			if strings.HasPrefix(arg, "-") {
+				continue
+			}
+			if strings.TrimSuffix(arg, "@latest") != g.mod {
+				return nil, []byte("no module " + arg), errors.New("exit status 1")
+			}
+			buf, _ := json.Marshal(map[string]string{"Path": g.mod, "Version": g.latest})
+			out = append(out, buf...)
+		}
+		return out, nil, nil
+	case "install":
+		target := args[len(args)-1]
+		g.mu.Lock()
+		g.installs = append(g.installs, target)
+		g.mu.Unlock()
+		var gobin string
+		for _, kv := range env {
+			if v, ok := strings.CutPrefix(kv, "GOBIN="); ok {
+				gobin = v
+			}
+		}
+		_, version, _ := strings.Cut(target, "@")
+		err := os.WriteFile(filepath.Join(gobin, "go-latest"), []byte("#!/bin/sh\necho "+version+"\n"), 0o755)
+		return nil, nil, err
+	}
+	return nil, []byte("unexpected"), errors.New("exit status 1")
+}
+
+func TestSelfUpdate(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("installs a shell script")
+	}
+	bi := &debug.BuildInfo{
+		Path: "example.com/go-latest/cmd/go-latest",
+		Main: debug.Module{Path: "example.com/go-latest", Version: "v1.0.0"},
+	}
+	for _, tt := range []struct {
+		name, latest string
+		installs     []string
+		want         string
+	}{
+		{"upgrade", "v1.1.0", []string{"example.com/go-latest/cmd/go-latest@v1.1.0"}, "example.com/go-latest/cmd/go-latest v1.0.0 -> v1.1.0\n"},
+		{"latest", "v1.0.0", nil, "example.com/go-latest/cmd/go-latest v1.0.0 already latest\n"},
+		{"newer", "v0.9.0", nil, "example.com/go-latest/cmd/go-latest v1.0.0 newer than latest v0.9.0\n"},
+	} {
+		exe := filepath.Join(t.TempDir(), "go-latest")
+		err := os.WriteFile(exe, []byte("#!/bin/sh\necho v1.0.0\n"), 0o755)
+		if err != nil {
+			t.Fatal(err)
+		}
+		g := &goSelf{mod: "example.com/go-latest", latest: tt.latest}
+		var b strings.Builder
+		err = update(context.Background(), &b, g, bi, exe)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if b.String() != tt.want {
+			t.Errorf("%s: wrote %q, want %q", tt.name, b.String(), tt.want)
+		}
+		if strings.Join(g.installs, " ") != strings.Join(tt.installs, " ") {
+			t.Errorf("%s: installed %q, want %q", tt.name, g.installs, tt.installs)
+		}
+		got, err := os.ReadFile(exe)
+		if err != nil {
+			t.Fatal(err)
+		}
+		version := "v1.0.0"
+		if tt.installs != nil {
+			version = tt.latest
+		}
+		if want := "#!/bin/sh\necho " + version + "\n"; string(got) != want {
+			t.Errorf("%s: left %q, want %q", tt.name, got, want)
+		}
+		if files, _ := os.ReadDir(filepath.Dir(exe)); len(files) != 1 {
+			t.Errorf("%s: left %d files next to go-latest, want none", tt.name, len(files)-1)
+		}
+	}
+}
+
+func TestSelfUpdateDevel(t *testing.T) {
+	bi := &debug.BuildInfo{Path: "example.com/go-latest", Main: debug.Module{Path: "example.com/go-latest", Version: "(devel)"}}
+	err := update(context.Background(), &strings.Builder{}, &goSelf{}, bi, filepath.Join(t.TempDir(), "go-latest"))
+	if err == nil || !strings.Contains(err.Error(), "was not go install-ed at a version") {
+		t.Errorf("got %v, want a (devel) build refused", err)
+	}
+}
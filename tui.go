@@ -0,0 +1,297 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/vikblom/go-latest/golatest"
+	"golang.org/x/term"
+)
+
+// Keys, as read from a terminal in raw mode.
+const (
+	keyCtrlC = "\x03"
+	keyUp    = "\x1b[A"
+	keyDown  = "\x1b[B"
+	keyPgUp  = "\x1b[5~"
+	keyPgDn  = "\x1b[6~"
+)
+
+// tui is a checklist of resolved upgrades to pick from, which then shows
+// how the installs of the picked ones go.
+type tui struct {
+	in, out *os.File
+	// cancel the run on Ctrl-C, which raw mode keeps from being a signal.
+	cancel context.CancelFunc
+	keys   chan string
+	// state to restore the terminal to, nil unless the UI is up.
+	state *term.State
+
+	mu     sync.Mutex
+	rows   []tuiRow
+	cursor int
+	top    int
+	// running installs, the selection is final.
+	running bool
+}
+
+type tuiRow struct {
+	up      *golatest.Pending
+	checked bool
+	status  string
+}
+
+func newTUI(in io.Reader, out io.Writer, cancel context.CancelFunc) (*tui, error) {
+	if !isTerminal(in) || !isTerminal(out) {
+		return nil, fmt.Errorf("-tui requires a terminal, try -interactive instead")
+	}
+	return &tui{in: in.(*os.File), out: out.(*os.File), cancel: cancel}, nil
+}
+
+// choose upgrades from a checklist, all checked to begin with.
+// The UI stays up to show the progress of the chosen ones until closed.
+func (t *tui) choose(ctx context.Context, ups []*golatest.Pending) ([]*golatest.Pending, error) {
+	if len(ups) == 0 {
+		return nil, nil
+	}
+	err := t.open()
+	if err != nil {
+		return nil, err
+	}
+
+	t.mu.Lock()
+	for _, up := range ups {
+		t.rows = append(t.rows, tuiRow{up: up, checked: true})
+	}
+	t.mu.Unlock()
+
+	for {
+		t.draw()
+		var key string
+		var ok bool
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case key, ok = <-t.keys:
+		}
+		if !ok {
+			// Nothing more to read, stdin is closed.
+			t.cancel()
+			return nil, context.Canceled
+		}
+
+		t.mu.Lock()
+		switch key {
+		case keyCtrlC:
+			t.mu.Unlock()
+			t.cancel()
+			return nil, context.Canceled
+		case "q":
+			t.mu.Unlock()
+			return nil, nil
+		case "\r", "\n":
+			t.running = true
+			var chosen []*golatest.Pending
+			for i, r := range t.rows {
+				if r.checked {
+					chosen = append(chosen, r.up)
+					t.rows[i].status = "waiting"
+				}
+			}
+			t.mu.Unlock()
+			// Keep Ctrl-C working while installing.
+			go func() {
+				for key := range t.keys {
+					if key == keyCtrlC {
+						t.cancel()
+					}
+				}
+			}()
+			return chosen, nil
+		case " ":
+			t.rows[t.cursor].checked = !t.rows[t.cursor].checked
+		case "a", "n":
+			for i := range t.rows {
+				t.rows[i].checked = key == "a"
+			}
+		case keyUp, "k":
+			t.cursor = max(t.cursor-1, 0)
+		case keyDown, "j":
+			t.cursor = min(t.cursor+1, len(t.rows)-1)
+		case keyPgUp:
+			t.cursor = max(t.cursor-t.height(), 0)
+		case keyPgDn:
+			t.cursor = min(t.cursor+t.height(), len(t.rows)-1)
+		}
+		t.mu.Unlock()
+	}
+}
+
+// event updates the status of the row of the program, if any.
+func (t *tui) event(e golatest.Event) {
+	if e.Kind != golatest.EventInstalling && e.Kind != golatest.EventInstalled {
+		return
+	}
+	res := e.Result
+	t.mu.Lock()
+	for i, r := range t.rows {
+		if r.up.Result.File != res.File {
+			continue
+		}
+		switch {
+		case e.Kind == golatest.EventInstalling:
+			t.rows[i].status = "installing"
+		case res.Err != nil:
+			t.rows[i].status = res.Action + ": " + firstLine(res.Err.Error())
+		default:
+			t.rows[i].status = res.Action + took("", res.Duration.String(), sizeDelta(res))
+		}
+	}
+	t.mu.Unlock()
+	t.draw()
+}
+
+func (t *tui) open() error {
+	state, err := term.MakeRaw(int(t.in.Fd()))
+	if err != nil {
+		return fmt.Errorf("terminal raw mode: %w", err)
+	}
+	t.state = state
+	// Alternate screen, hidden cursor.
+	fmt.Fprint(t.out, "\x1b[?1049h\x1b[?25l")
+
+	t.keys = make(chan string)
+	go func() {
+		buf := make([]byte, 16)
+		for {
+			n, err := t.in.Read(buf)
+			if err != nil {
+				close(t.keys)
+				return
+			}
+			t.keys <- string(buf[:n])
+		}
+	}()
+	return nil
+}
+
+// close the UI, restoring the terminal. Safe to call more than once.
+func (t *tui) close() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.state == nil {
+		return
+	}
+	fmt.Fprint(t.out, "\x1b[?25h\x1b[?1049l")
+	term.Restore(int(t.in.Fd()), t.state)
+	t.state = nil
+}
+
+// height of the list, leaving room for a header and footer.
+func (t *tui) height() int {
+	_, h, err := term.GetSize(int(t.out.Fd()))
+	if err != nil || h < 3 {
+		return 20
+	}
+	return h - 2
+}
+
+func (t *tui) draw() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.state == nil {
+		return
+	}
+	width, _, err := term.GetSize(int(t.out.Fd()))
+	if err != nil || width <= 0 {
+		width = 80
+	}
+	height := t.height()
+	if t.cursor < t.top {
+		t.top = t.cursor
+	}
+	if t.cursor >= t.top+height {
+		t.top = t.cursor - height + 1
+	}
+
+	var b bytes.Buffer
+	line := func(s string) {
+		b.WriteString(truncate(s, width) + "\x1b[K\r\n")
+	}
+	b.WriteString("\x1b[H")
+	if t.running {
+		line("go-latest: installing, ctrl-c to stop")
+	} else {
+		line("go-latest: space toggle, a all, n none, enter install, q quit")
+	}
+	checked := 0
+	for i, r := range t.rows {
+		if r.checked {
+			checked++
+		}
+		if i < t.top || i >= t.top+height {
+			continue
+		}
+		cursor, box := " ", "[ ]"
+		if i == t.cursor && !t.running {
+			cursor = ">"
+		}
+		if r.checked {
+			box = "[x]"
+		}
+		res := r.up.Result
+		line(fmt.Sprintf("%s %s %s %s -> %s  %s", cursor, box, res.Path, res.Current, res.Latest, r.status))
+	}
+	b.WriteString(fmt.Sprintf("%d of %d selected\x1b[K\x1b[J", checked, len(t.rows)))
+	t.out.Write(b.Bytes())
+}
+
+func firstLine(s string) string {
+	s, _, _ = strings.Cut(strings.TrimSpace(s), "\n")
+	return s
+}
+
+// holdWriter buffers everything written to it until released,
+// keeping output from scribbling over the terminal UI.
+type holdWriter struct {
+	mu   sync.Mutex
+	w    io.Writer
+	buf  bytes.Buffer
+	held bool
+}
+
+func newHoldWriter(w io.Writer) *holdWriter {
+	return &holdWriter{w: w, held: true}
+}
+
+func (h *holdWriter) Write(p []byte) (int, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.held {
+		return h.buf.Write(p)
+	}
+	return h.w.Write(p)
+}
+
+// release what has been held, writing through from now on.
+func (h *holdWriter) release() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.held = false
+	_, err := h.buf.WriteTo(h.w)
+	return err
+}
+
+// truncate s to width runes, lest a line wrap, never splitting one.
+func truncate(s string, width int) string {
+	if utf8.RuneCountInString(s) <= width {
+		return s
+	}
+	return string([]rune(s)[:max(width, 0)])
+}
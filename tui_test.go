@@ -0,0 +1,22 @@
+package main
+
+import "testing"
+
+func TestTruncate(t *testing.T) {
+	for _, tt := range []struct {
+		s     string
+		width int
+		want  string
+	}{
+		{"example.com/tool", 80, "example.com/tool"},
+		{"example.com/tool", 7, "example"},
+		{"example.com/tool", 0, ""},
+		{"example.com/ツール", 14, "example.com/ツー"},
+		{"example.com/ツール", 15, "example.com/ツール"},
+		{"ü", 1, "ü"},
+	} {
+		if got := truncate(tt.s, tt.width); got != tt.want {
+			t.Errorf("truncate(%q, %d) = %q, want %q", tt.s, tt.width, got, tt.want)
+		}
+	}
+}